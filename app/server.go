@@ -1,10 +1,13 @@
 package app
 
 import (
+	"cmp"
 	"fmt"
 	"log/slog"
-	"os"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"clickhouse-mcp/clickhouse"
 	"clickhouse-mcp/mcp"
@@ -12,16 +15,78 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// ConnectionConfig 一个ClickHouse连接的参数
+type ConnectionConfig struct {
+	// ClickhouseURL clickhouse-go格式的DSN，见clickhouse.ParseDSN
+	ClickhouseURL string
+	// Username、Password、Database 非空时覆盖DSN中的值
+	Username string
+	Password string
+	Database string
+	// Secure 为true时覆盖DSN，使用TLS连接
+	Secure bool
+	// TLSCAFile、TLSCertFile、TLSKeyFile PEM格式的CA证书、客户端证书和私钥文件
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSServerName 用于SNI和校验服务端证书的主机名
+	TLSServerName string
+	// TLSMinVersion 最低TLS版本(1.0、1.1、1.2、1.3)，默认1.2
+	TLSMinVersion string
+	// TLSInsecure 不校验服务端证书，与DSN中的skip_verify相同
+	TLSInsecure bool
+	// HTTPHeaders HTTP接口随每个请求发送的HTTP头，每项为"Name: value"
+	HTTPHeaders []string
+	// HTTPProxy、HTTPPath HTTP接口的代理URL和URL路径，非空时覆盖DSN
+	HTTPProxy string
+	HTTPPath  string
+	// ConnOpenStrategy URL中有多个地址时的连接策略(in_order、round_robin、random)，非空时覆盖DSN
+	ConnOpenStrategy string
+}
+
 // ServerConfig 包含服务器配置
 type ServerConfig struct {
-	Transport     string
-	TestMode      bool
-	ClickhouseURL string
-	Username      string
-	Password      string
-	Database      string
-	Secure        bool
-	Port          int
+	Transport string
+	TestMode  bool
+	// ConnectionConfig 未配置Connections时唯一的连接"default"
+	ConnectionConfig
+	// Connections 按名称的连接，工具的connection参数从中选择；为空时只使用ConnectionConfig
+	Connections map[string]ConnectionConfig
+	// DefaultConnection 工具未指定connection参数时使用的连接，只有一个连接时可以为空
+	DefaultConnection string
+	Port              int
+	// AllowedStatements 逗号分隔的允许语句类别(read,ddl,dml,admin)，默认只读
+	AllowedStatements string
+	// NumericMode 大整数和Decimal的默认编码方式(number或string)
+	NumericMode string
+	// Format query工具默认的输出格式(json、compact、jsonl、csv、tsv、markdown)
+	Format string
+	// Settings 逗号分隔的query工具可按调用修改的设置及上限，如"max_execution_time=60,use_query_cache"
+	Settings string
+	// MaxRows query工具最多返回的行数，0表示不限制
+	MaxRows int
+	// MaxResultBytes query工具结果的最大字节数，0表示不限制
+	MaxResultBytes int
+	// MaxEstimatedRows、MaxEstimatedParts、MaxEstimatedBytes 执行SELECT前
+	// 按EXPLAIN ESTIMATE检查的读取行数、数据片段数和字节数上限，0表示不检查
+	MaxEstimatedRows  uint64
+	MaxEstimatedParts uint64
+	MaxEstimatedBytes uint64
+	// MaxResponseBytes 工具响应的最大字节数，0表示不限制
+	MaxResponseBytes int
+	// MaxResponseTokens 工具响应的最大token数(估算)，0表示不限制
+	MaxResponseTokens int
+	// ValidateQueries query工具执行前先由服务端检查查询语法
+	ValidateQueries bool
+	// StackTraces 在ClickHouse错误中返回服务端调用栈
+	StackTraces bool
+	// RetryAttempts 只读操作遇到暂时性错误时的最大尝试次数，小于等于1时不重试
+	RetryAttempts int
+	// RetryBackoff、RetryMaxBackoff 第一次重试前的等待时间及其上限，之后每次翻倍
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	// HealthCheckInterval 多个地址时后台健康检查的间隔，0表示不检查
+	HealthCheckInterval time.Duration
 }
 
 // Server 封装了MCP服务器的启动和配置逻辑
@@ -29,59 +94,12 @@ type Server struct {
 	config        ServerConfig
 	mcpServer     *server.MCPServer
 	tools         mcp.ToolHandler
-	chClient      clickhouse.Client
+	connections   *clickhouse.Registry
 	clickhouseDSN string
 }
 
-// ParseClickhouseURL 解析ClickHouse连接URL
-func ParseClickhouseURL(url string) (string, int, string, error) {
-	// 测试模式返回默认值
-	if url == "" || strings.HasPrefix(url, "localhost") {
-		return "localhost", 9000, "default", nil
-	}
-
-	// 移除协议前缀
-	cleanURL := url
-	cleanURL = strings.TrimPrefix(cleanURL, "clickhouse://")
-
-	// 分割为host:port/database格式
-	parts := strings.Split(cleanURL, "/")
-
-	var host string
-	var port int = 9000             // 默认端口
-	var database string = "default" // 默认数据库
-
-	if len(parts) > 0 {
-		hostPort := parts[0]
-		hostPortParts := strings.Split(hostPort, ":")
-
-		host = hostPortParts[0]
-		if len(hostPortParts) > 1 {
-			_, err := fmt.Sscanf(hostPortParts[1], "%d", &port)
-			if err != nil {
-				port = 9000 // 解析失败使用默认值
-			}
-		}
-
-		if len(parts) > 1 {
-			database = parts[1]
-		}
-	}
-
-	if host == "" {
-		return "", 0, "", fmt.Errorf("无效的ClickHouse URL格式: %s", url)
-	}
-
-	return host, port, database, nil
-}
-
 // NewServer 创建新的服务器实例
 func NewServer(config ServerConfig) (*Server, error) {
-	// 配置日志
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	})))
-
 	// 创建服务器
 	server := &Server{
 		config:        config,
@@ -95,13 +113,46 @@ func NewServer(config ServerConfig) (*Server, error) {
 		return server, nil
 	}
 
-	// 连接ClickHouse
-	if err := server.connectToClickhouse(); err != nil {
+	allowed, err := parseAllowedStatements(config.AllowedStatements)
+	if err != nil {
+		return nil, err
+	}
+
+	numeric, err := clickhouse.ParseNumericMode(config.NumericMode)
+	if err != nil {
+		return nil, fmt.Errorf("无效的数值编码配置: %w", err)
+	}
+
+	settings, err := clickhouse.ParseSettingsPolicy(config.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("无效的查询设置配置: %w", err)
+	}
+
+	format, err := mcp.ParseOutputFormat(config.Format)
+	if err != nil {
+		return nil, fmt.Errorf("无效的输出格式配置: %w", err)
+	}
+
+	// 注册ClickHouse连接，客户端在第一次使用时创建
+	if err := server.registerConnections(isReadOnly(allowed)); err != nil {
 		return nil, err
 	}
 
 	// 创建工具处理器
-	server.tools = mcp.NewToolHandler(server.chClient)
+	server.tools = mcp.NewToolHandler(server.connections, mcp.ToolConfig{
+		AllowedStatements: allowed,
+		NumericMode:       numeric,
+		DefaultFormat:     format,
+		Settings:          settings,
+		MaxRows:           config.MaxRows,
+		MaxResultBytes:    config.MaxResultBytes,
+		ResponseBudget: mcp.ResponseBudget{
+			MaxBytes:  config.MaxResponseBytes,
+			MaxTokens: config.MaxResponseTokens,
+		},
+		ValidateQueries: config.ValidateQueries,
+		StackTraces:     config.StackTraces,
+	})
 
 	// 创建MCP服务器
 	server.mcpServer = server.createMCPServer()
@@ -112,38 +163,179 @@ func NewServer(config ServerConfig) (*Server, error) {
 	return server, nil
 }
 
-// connectToClickhouse 建立与ClickHouse的连接
-func (s *Server) connectToClickhouse() error {
-	host, port, database, err := ParseClickhouseURL(s.config.ClickhouseURL)
+// parseAllowedStatements 解析允许的语句类别，未配置时只允许只读语句
+func parseAllowedStatements(list string) ([]clickhouse.StatementKind, error) {
+	if strings.TrimSpace(list) == "" {
+		return []clickhouse.StatementKind{clickhouse.StatementRead}, nil
+	}
+	allowed, err := clickhouse.ParseStatementKinds(list)
+	if err != nil {
+		return nil, fmt.Errorf("无效的语句类别配置: %w", err)
+	}
+	return allowed, nil
+}
+
+// isReadOnly 判断允许的语句类别是否只包含只读语句
+func isReadOnly(allowed []clickhouse.StatementKind) bool {
+	for _, kind := range allowed {
+		if kind != clickhouse.StatementRead {
+			return false
+		}
+	}
+	return true
+}
+
+// clickhouseConfig 返回连接conn的客户端配置，健康检查间隔、只读模式、成本上限和重试策略对所有连接相同
+func (c ServerConfig) clickhouseConfig(conn ConnectionConfig, readOnly bool) (clickhouse.Config, error) {
+	cfg, err := conn.clickhouseConfig()
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.HealthCheckInterval = c.HealthCheckInterval
+	cfg.ReadOnly = readOnly
+	cfg.CostLimits = clickhouse.CostLimits{
+		MaxRows:  c.MaxEstimatedRows,
+		MaxParts: c.MaxEstimatedParts,
+		MaxBytes: c.MaxEstimatedBytes,
+	}
+	cfg.Retry = clickhouse.RetryPolicy{
+		MaxAttempts:    c.RetryAttempts,
+		InitialBackoff: c.RetryBackoff,
+		MaxBackoff:     c.RetryMaxBackoff,
+	}
+	return cfg, nil
+}
+
+// profiles 返回所有连接的客户端配置，未配置Connections时只有连接"default"
+func (c ServerConfig) profiles(readOnly bool) ([]clickhouse.Profile, error) {
+	connections := c.Connections
+	if len(connections) == 0 {
+		connections = map[string]ConnectionConfig{clickhouse.DefaultConnection: c.ConnectionConfig}
+	}
+
+	profiles := make([]clickhouse.Profile, 0, len(connections))
+	for _, name := range slices.Sorted(maps.Keys(connections)) {
+		cfg, err := c.clickhouseConfig(connections[name], readOnly)
+		if err != nil {
+			return nil, fmt.Errorf("连接%s: %w", name, err)
+		}
+		profiles = append(profiles, clickhouse.Profile{Name: name, Config: cfg})
+	}
+	return profiles, nil
+}
+
+// clickhouseConfig 解析ClickhouseURL中的DSN，显式配置的用户名、密码、数据库、
+// TLS、HTTP接口参数和连接策略覆盖DSN中的值
+func (c ConnectionConfig) clickhouseConfig() (clickhouse.Config, error) {
+	// -secure等同于DSN中的secure，未指定端口的地址使用TLS端口
+	parse := clickhouse.ParseDSN
+	if c.Secure {
+		parse = clickhouse.ParseSecureDSN
+	}
+	cfg, err := parse(c.ClickhouseURL)
+	if err != nil {
+		return cfg, fmt.Errorf("无效的ClickHouse URL: %w", err)
+	}
+
+	if c.Username != "" {
+		cfg.Username = c.Username
+	}
+	if c.Password != "" {
+		cfg.Password = c.Password
+	}
+	if c.Database != "" {
+		cfg.Database = c.Database
+	}
+	if err := c.applyTLS(&cfg.TLS); err != nil {
+		return cfg, err
+	}
+	if err := c.applyHTTP(&cfg); err != nil {
+		return cfg, err
+	}
+	if c.ConnOpenStrategy != "" {
+		if cfg.ConnOpenStrategy, err = clickhouse.ParseConnOpenStrategy(c.ConnOpenStrategy); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// applyTLS 用配置的TLS参数覆盖DSN中的TLS配置
+func (c ConnectionConfig) applyTLS(tlsConfig *clickhouse.TLSConfig) error {
+	version, err := clickhouse.ParseTLSVersion(c.TLSMinVersion)
 	if err != nil {
 		return err
 	}
+	if version != 0 {
+		tlsConfig.MinVersion = version
+	}
+	if c.TLSCAFile != "" {
+		tlsConfig.CAFile = c.TLSCAFile
+	}
+	if c.TLSCertFile != "" {
+		tlsConfig.CertFile = c.TLSCertFile
+	}
+	if c.TLSKeyFile != "" {
+		tlsConfig.KeyFile = c.TLSKeyFile
+	}
+	if c.TLSServerName != "" {
+		tlsConfig.ServerName = c.TLSServerName
+	}
+	if c.TLSInsecure {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	return nil
+}
 
-	slog.Info("连接ClickHouse",
-		"host", host,
-		"port", port,
-		"database", database,
-	)
+// applyHTTP 设置HTTP接口的请求头，并用配置的代理和路径覆盖DSN中的值
+func (c ConnectionConfig) applyHTTP(cfg *clickhouse.Config) error {
+	for _, header := range c.HTTPHeaders {
+		name, value, err := clickhouse.ParseHTTPHeader(header)
+		if err != nil {
+			return err
+		}
+		if cfg.HTTPHeaders == nil {
+			cfg.HTTPHeaders = map[string]string{}
+		}
+		cfg.HTTPHeaders[name] = value
+	}
+	if c.HTTPProxy != "" {
+		cfg.HTTPProxy = c.HTTPProxy
+	}
+	if c.HTTPPath != "" {
+		cfg.HTTPPath = c.HTTPPath
+	}
+	return nil
+}
 
-	// 如果配置中指定了数据库则使用配置值
-	if s.config.Database != "" {
-		database = s.config.Database
+// registerConnections 检查所有连接的配置并创建连接注册表，客户端在连接第一次被使用时创建
+func (s *Server) registerConnections(readOnly bool) error {
+	profiles, err := s.config.profiles(readOnly)
+	if err != nil {
+		return err
 	}
 
-	// 创建ClickHouse客户端
-	client, err := clickhouse.NewClient(clickhouse.Config{
-		Host:     host,
-		Port:     port,
-		Database: database,
-		Username: s.config.Username,
-		Password: s.config.Password,
-		Secure:   s.config.Secure,
-	})
+	connections, err := clickhouse.NewRegistry(profiles, s.config.DefaultConnection)
 	if err != nil {
-		return fmt.Errorf("连接ClickHouse失败: %w", err)
+		return fmt.Errorf("无效的连接配置: %w", err)
+	}
+
+	for _, profile := range profiles {
+		cfg := profile.Config
+		slog.Info("注册ClickHouse连接",
+			"name", profile.Name,
+			"default", profile.Name == connections.Default(),
+			"protocol", cmp.Or(cfg.Protocol, clickhouse.ProtocolNative),
+			"addr", strings.Join(cfg.Addr, ","),
+			"strategy", cmp.Or(cfg.ConnOpenStrategy, clickhouse.ConnOpenInOrder),
+			"database", cfg.Database,
+			"secure", cfg.Secure,
+			"readonly", readOnly,
+		)
 	}
 
-	s.chClient = client
+	s.connections = connections
 	return nil
 }
 
@@ -172,6 +364,12 @@ func (s *Server) RunTests() {
 	fmt.Println("\n=== 执行SQL查询请求示例 ===")
 	fmt.Println(`{"jsonrpc":"2.0","id":"test","method":"mcp.call","params":{"tool":"query","arguments":{"query":"SELECT 1 as test","limit":10}}}`)
 
+	fmt.Println("\n=== 查看连接列表请求示例 ===")
+	fmt.Println(`{"jsonrpc":"2.0","id":"test","method":"mcp.call","params":{"tool":"list_connections","arguments":{}}}`)
+
+	fmt.Println("\n=== 在指定连接中执行查询请求示例 ===")
+	fmt.Println(`{"jsonrpc":"2.0","id":"test","method":"mcp.call","params":{"tool":"query","arguments":{"query":"SELECT version()","connection":"staging"}}}`)
+
 	fmt.Println("\n请在不使用-test标志的情况下启动服务器并通过MCP客户端发送请求")
 }
 
@@ -199,10 +397,10 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Close 关闭连接
+// Close 关闭所有已建立的连接
 func (s *Server) Close() error {
-	if s.chClient != nil {
-		return s.chClient.Close()
+	if s.connections != nil {
+		return s.connections.Close()
 	}
 	return nil
 }
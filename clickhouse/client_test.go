@@ -1,7 +1,17 @@
 package clickhouse
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/shopspring/decimal"
 )
 
 func TestIsArrayType(t *testing.T) {
@@ -90,184 +100,473 @@ func TestGetBaseType(t *testing.T) {
 	}
 }
 
-func TestNormalizeQuery(t *testing.T) {
-	tests := []struct {
-		name  string
-		query string
-		want  string
-	}{
-		{
-			name:  "Запрос без точки с запятой",
-			query: "SELECT 1",
-			want:  "SELECT 1",
-		},
-		{
-			name:  "Запрос с точкой с запятой",
-			query: "SELECT 1;",
-			want:  "SELECT 1",
-		},
-		{
-			name:  "Запрос с лишними пробелами",
-			query: "  SELECT   1  ; ",
-			want:  "SELECT   1",
-		},
-		{
-			name:  "Пустой запрос",
-			query: "",
-			want:  "",
-		},
-		{
-			name:  "Только точка с запятой",
-			query: ";",
-			want:  "",
-		},
+// fakeColumnType - тестовое описание столбца
+type fakeColumnType struct {
+	name string
+	typ  string
+	// scan - тип для сканирования, по умолчанию any
+	scan reflect.Type
+}
+
+func (c fakeColumnType) Name() string             { return c.name }
+func (c fakeColumnType) Nullable() bool           { return false }
+func (c fakeColumnType) DatabaseTypeName() string { return c.typ }
+
+func (c fakeColumnType) ScanType() reflect.Type {
+	if c.scan != nil {
+		return c.scan
 	}
+	return reflect.TypeOf((*any)(nil)).Elem()
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := normalizeQuery(tt.query)
-			if got != tt.want {
-				t.Errorf("normalizeQuery() = %v, want %v", got, tt.want)
+// fakeRows - тестовый набор строк
+type fakeRows struct {
+	driver.Rows
+	columns []fakeColumnType
+	data    [][]any
+	pos     int
+	// err - ошибка сервера, возвращаемая вместо результата
+	err error
+}
+
+func (r *fakeRows) Next() bool {
+	r.pos++
+	return r.pos <= len(r.data)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	for i, d := range dest {
+		target := reflect.ValueOf(d).Elem()
+		value := r.data[r.pos-1][i]
+		if value == nil {
+			// Как и драйвер, NULL не сбрасывает значение цели
+			continue
+		}
+		v := reflect.ValueOf(value)
+		if target.Kind() != reflect.Interface && v.Type() != target.Type() {
+			if !v.CanConvert(target.Type()) {
+				return fmt.Errorf("converting %T to %s is unsupported", value, target.Type())
 			}
-		})
+			v = v.Convert(target.Type())
+		}
+		target.Set(v)
 	}
+	return nil
 }
 
-func TestContainsLimitClause(t *testing.T) {
-	tests := []struct {
-		name  string
-		query string
-		want  bool
-	}{
-		{
-			name:  "Запрос без LIMIT",
-			query: "SELECT * FROM table",
-			want:  false,
-		},
-		{
-			name:  "Запрос с LIMIT",
-			query: "SELECT * FROM table LIMIT 10",
-			want:  true,
-		},
-		{
-			name:  "Запрос с limit в нижнем регистре",
-			query: "select * from table limit 100",
-			want:  true,
-		},
-		{
-			name:  "LIMIT в комментарии",
-			query: "SELECT * FROM table /* LIMIT 10 */",
-			want:  false,
-		},
-		{
-			name:  "LIMIT в строке",
-			query: "SELECT 'LIMIT 10' FROM table",
-			want:  false,
-		},
-		{
-			name:  "Пустой запрос",
-			query: "",
-			want:  false,
-		},
+func (r *fakeRows) ColumnTypes() []driver.ColumnType {
+	types := make([]driver.ColumnType, len(r.columns))
+	for i, c := range r.columns {
+		types[i] = c
 	}
+	return types
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := containsLimitClause(tt.query)
-			if got != tt.want {
-				t.Errorf("containsLimitClause() = %v, want %v", got, tt.want)
-			}
-		})
+func (r *fakeRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		names[i] = c.name
 	}
+	return names
 }
 
-func TestEndsWithSemicolon(t *testing.T) {
-	tests := []struct {
-		name  string
-		query string
-		want  bool
-	}{
-		{
-			name:  "Запрос без точки с запятой",
-			query: "SELECT 1",
-			want:  false,
-		},
-		{
-			name:  "Запрос с точкой с запятой",
-			query: "SELECT 1;",
-			want:  true,
-		},
-		{
-			name:  "Запрос с точкой с запятой и пробелами",
-			query: "SELECT 1  ;  ",
-			want:  true,
-		},
-		{
-			name:  "Пустой запрос",
-			query: "",
-			want:  false,
-		},
-		{
-			name:  "Только точка с запятой",
-			query: ";",
-			want:  true,
-		},
+func (r *fakeRows) Close() error { return nil }
+func (r *fakeRows) Err() error   { return nil }
+
+// fakeRow - тестовая строка для QueryRow
+type fakeRow struct {
+	driver.Row
+	rows *fakeRows
+}
+
+func (r *fakeRow) Err() error { return nil }
+
+func (r *fakeRow) Scan(dest ...any) error {
+	if r.rows.err != nil {
+		return r.rows.err
+	}
+	if !r.rows.Next() {
+		return fmt.Errorf("no rows")
 	}
+	return r.rows.Scan(dest...)
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := endsWithSemicolon(tt.query)
-			if got != tt.want {
-				t.Errorf("endsWithSemicolon() = %v, want %v", got, tt.want)
+// fakeConn - тестовое соединение, запоминающее выполненные запросы.
+// Ответы выдаются по очереди, последний повторяется
+type fakeConn struct {
+	driver.Conn
+	responses []*fakeRows
+	queries   []string
+	// timezone - часовой пояс сервера, по умолчанию UTC
+	timezone *time.Location
+	// queryCtx - контекст последнего вызова Query
+	queryCtx context.Context
+}
+
+func (c *fakeConn) next(query string) *fakeRows {
+	c.queries = append(c.queries, query)
+	rows := *c.responses[0]
+	if len(c.responses) > 1 {
+		c.responses = c.responses[1:]
+	}
+	return &rows
+}
+
+func (c *fakeConn) Ping(context.Context) error { return nil }
+
+func (c *fakeConn) ServerVersion() (*driver.ServerVersion, error) {
+	tz := c.timezone
+	if tz == nil {
+		tz = time.UTC
+	}
+	return &driver.ServerVersion{Timezone: tz}, nil
+}
+
+func (c *fakeConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	c.queryCtx = ctx
+	rows := c.next(query)
+	if rows.err != nil {
+		return nil, rows.err
+	}
+	return rows, nil
+}
+
+func (c *fakeConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return &fakeRow{rows: c.next(query)}
+}
+
+func TestQueryDataLimit(t *testing.T) {
+	conn := &fakeConn{responses: []*fakeRows{{
+		columns: []fakeColumnType{{name: "number", typ: "UInt64"}},
+		data:    [][]any{{uint64(0)}, {uint64(1)}, {uint64(2)}},
+	}}}
+	client := &DefaultClient{conn: conn}
+
+	t.Run("Результат обрезан", func(t *testing.T) {
+		result, err := client.QueryData(context.Background(), "SELECT number FROM numbers(3);", QueryOptions{Limit: 2})
+		if err != nil {
+			t.Fatalf("QueryData() error = %v", err)
+		}
+		if len(result.Rows) != 2 || !result.Truncated {
+			t.Errorf("QueryData() вернул %d строк, truncated = %v, want 2 и true", len(result.Rows), result.Truncated)
+		}
+		want := "SELECT * FROM (\nSELECT number FROM numbers(3)\n) LIMIT 3"
+		if got := conn.queries[len(conn.queries)-1]; got != want {
+			t.Errorf("выполнен запрос %q, want %q", got, want)
+		}
+		if result.Stats == nil || result.Stats.QueryID == "" || result.Stats.ResultRows != 2 {
+			t.Errorf("QueryData() stats = %+v, want query_id и result_rows = 2", result.Stats)
+		}
+	})
+
+	t.Run("Результат не обрезан", func(t *testing.T) {
+		result, err := client.QueryData(context.Background(), "SELECT number FROM numbers(3)", QueryOptions{Limit: 3})
+		if err != nil {
+			t.Fatalf("QueryData() error = %v", err)
+		}
+		if len(result.Rows) != 3 || result.Truncated {
+			t.Errorf("QueryData() вернул %d строк, truncated = %v, want 3 и false", len(result.Rows), result.Truncated)
+		}
+	})
+}
+
+func TestQueryDataDecode(t *testing.T) {
+	name := "a"
+	conn := &fakeConn{responses: []*fakeRows{{
+		columns: []fakeColumnType{
+			{name: "name", typ: "Nullable(String)", scan: reflect.TypeOf(&name)},
+			{name: "price", typ: "Decimal(10, 2)", scan: reflect.TypeOf(decimal.Decimal{})},
+			{name: "day", typ: "Date", scan: reflect.TypeOf(time.Time{})},
+			{name: "tags", typ: "Array(LowCardinality(String))", scan: reflect.TypeOf([]string{})},
+		},
+		data: [][]any{
+			{&name, decimal.RequireFromString("1.5"), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), []string{"x"}},
+			{nil, decimal.RequireFromString("2"), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), []string{}},
+		},
+	}}}
+	client := &DefaultClient{conn: conn}
+
+	result, err := client.QueryData(context.Background(), "SELECT * FROM items", QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryData() error = %v", err)
+	}
+
+	want := [][]any{
+		{"a", json.Number("1.50"), "2024-01-02", []any{"x"}},
+		// NULL не должен сохранять значение предыдущей строки
+		{nil, json.Number("2.00"), "2024-01-03", []any{}},
+	}
+	if !reflect.DeepEqual(result.Rows, want) {
+		t.Errorf("QueryData() rows = %#v, want %#v", result.Rows, want)
+	}
+}
+
+func TestQueryStream(t *testing.T) {
+	newConn := func() *fakeConn {
+		return &fakeConn{responses: []*fakeRows{{
+			columns: []fakeColumnType{{name: "number", typ: "UInt64", scan: reflect.TypeOf(uint64(0))}},
+			data:    [][]any{{uint64(0)}, {uint64(1)}, {uint64(2)}},
+		}}}
+	}
+
+	t.Run("Все строки", func(t *testing.T) {
+		client := &DefaultClient{conn: newConn()}
+		var got [][]any
+		result, err := client.QueryStream(context.Background(), "SELECT number FROM numbers(3)", QueryOptions{}, func(row []any) error {
+			got = append(got, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("QueryStream() error = %v", err)
+		}
+		want := [][]any{{uint64(0)}, {uint64(1)}, {uint64(2)}}
+		if !reflect.DeepEqual(got, want) || result.Truncated || result.Rows != nil {
+			t.Errorf("QueryStream() rows = %v, truncated = %v, result.Rows = %v", got, result.Truncated, result.Rows)
+		}
+	})
+
+	t.Run("Остановка отменяет запрос", func(t *testing.T) {
+		conn := newConn()
+		client := &DefaultClient{conn: conn}
+		calls := 0
+		result, err := client.QueryStream(context.Background(), "SELECT number FROM numbers(3)", QueryOptions{}, func(row []any) error {
+			calls++
+			if calls == 2 {
+				return ErrStopQuery
 			}
+			return nil
 		})
-	}
+		if err != nil {
+			t.Fatalf("QueryStream() error = %v", err)
+		}
+		if calls != 2 || !result.Truncated {
+			t.Errorf("QueryStream() calls = %d, truncated = %v, want 2 и true", calls, result.Truncated)
+		}
+		if conn.queryCtx.Err() == nil {
+			t.Error("контекст запроса не отменён")
+		}
+	})
+
+	t.Run("Сервер остановлен по max_result_bytes", func(t *testing.T) {
+		tests := []struct {
+			protocol       Protocol
+			maxResultBytes int
+			want           bool
+		}{
+			// Три значения UInt64 по 9 байт в оценке
+			{protocol: ProtocolHTTP, maxResultBytes: 27, want: true},
+			{protocol: ProtocolHTTP, maxResultBytes: 28, want: false},
+			{protocol: ProtocolNative, maxResultBytes: 27, want: false},
+		}
+		for _, tt := range tests {
+			client := &DefaultClient{conn: newConn(), protocol: tt.protocol}
+			result, err := client.QueryStream(context.Background(), "SELECT number FROM numbers(3)",
+				QueryOptions{MaxResultBytes: tt.maxResultBytes}, func(row []any) error { return nil })
+			if err != nil {
+				t.Fatalf("QueryStream() error = %v", err)
+			}
+			if result.Truncated != tt.want {
+				t.Errorf("%s, max_result_bytes = %d: truncated = %v, want %v", tt.protocol, tt.maxResultBytes, result.Truncated, tt.want)
+			}
+		}
+	})
+
+	t.Run("Ошибка обработчика", func(t *testing.T) {
+		client := &DefaultClient{conn: newConn()}
+		wantErr := errors.New("boom")
+		_, err := client.QueryStream(context.Background(), "SELECT number FROM numbers(3)", QueryOptions{}, func(row []any) error {
+			return wantErr
+		})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("QueryStream() error = %v, want %v", err, wantErr)
+		}
+	})
 }
 
-func TestRemoveComments(t *testing.T) {
+func TestQueryDataTimezone(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("нет базы часовых поясов: %v", err)
+	}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	newConn := func() *fakeConn {
+		return &fakeConn{
+			timezone: moscow,
+			responses: []*fakeRows{{
+				columns: []fakeColumnType{
+					{name: "local", typ: "DateTime64(6, 'Asia/Shanghai')", scan: reflect.TypeOf(ts)},
+					{name: "server", typ: "DateTime", scan: reflect.TypeOf(ts)},
+					{name: "day", typ: "Date", scan: reflect.TypeOf(ts)},
+				},
+				data: [][]any{{ts, ts.In(moscow), ts}},
+			}},
+		}
+	}
+
 	tests := []struct {
-		name  string
-		query string
-		want  string
+		name          string
+		timezone      string
+		wantRow       []any
+		wantTimezones []string
+		wantResult    string
 	}{
 		{
-			name:  "Запрос без комментариев",
-			query: "SELECT * FROM table",
-			want:  "SELECT * FROM table",
+			name:          "Часовые пояса столбцов",
+			wantRow:       []any{"2024-01-02T11:04:05.123456+08:00", "2024-01-02T06:04:05+03:00", "2024-01-02"},
+			wantTimezones: []string{"Asia/Shanghai", "Europe/Moscow", ""},
 		},
 		{
-			name:  "Запрос с многострочным комментарием",
-			query: "SELECT * FROM table /* это комментарий */ WHERE id = 1",
-			want:  "SELECT * FROM table   WHERE id = 1",
+			name:          "Приведение к UTC",
+			timezone:      "UTC",
+			wantRow:       []any{"2024-01-02T03:04:05.123456Z", "2024-01-02T03:04:05Z", "2024-01-02"},
+			wantTimezones: []string{"UTC", "UTC", ""},
+			wantResult:    "UTC",
 		},
 		{
-			name:  "Запрос с многострочным комментарием в конце",
-			query: "SELECT * FROM table /* это комментарий */",
-			want:  "SELECT * FROM table  ",
-		},
-		{
-			name:  "Запрос с незакрытым многострочным комментарием",
-			query: "SELECT * FROM table /* незакрытый комментарий",
-			want:  "SELECT * FROM table ",
-		},
-		{
-			name:  "Запрос с однострочным комментарием",
-			query: "SELECT * FROM table -- это комментарий\nWHERE id = 1",
-			want:  "SELECT * FROM table \nWHERE id = 1",
-		},
-		{
-			name:  "Запрос с несколькими комментариями",
-			query: "SELECT * /* внутри */ FROM table -- конец строки\nWHERE /* условие */ id = 1",
-			want:  "SELECT *   FROM table \nWHERE   id = 1",
+			name:          "Часовой пояс сессии",
+			timezone:      TimezoneSession,
+			wantRow:       []any{"2024-01-02T06:04:05.123456+03:00", "2024-01-02T06:04:05+03:00", "2024-01-02"},
+			wantTimezones: []string{"Europe/Moscow", "Europe/Moscow", ""},
+			wantResult:    "Europe/Moscow",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := removeComments(tt.query)
-			if got != tt.want {
-				t.Errorf("removeComments() = %v, want %v", got, tt.want)
+			client := &DefaultClient{conn: newConn()}
+			result, err := client.QueryData(context.Background(), "SELECT * FROM events", QueryOptions{Timezone: tt.timezone})
+			if err != nil {
+				t.Fatalf("QueryData() error = %v", err)
+			}
+			if !reflect.DeepEqual(result.Rows[0], tt.wantRow) {
+				t.Errorf("QueryData() row = %v, want %v", result.Rows[0], tt.wantRow)
+			}
+			for i, col := range result.Columns {
+				if col.Timezone != tt.wantTimezones[i] {
+					t.Errorf("столбец %s: timezone = %q, want %q", col.Name, col.Timezone, tt.wantTimezones[i])
+				}
+			}
+			if result.Timezone != tt.wantResult {
+				t.Errorf("QueryData() timezone = %q, want %q", result.Timezone, tt.wantResult)
 			}
 		})
 	}
+
+	t.Run("Неизвестный часовой пояс", func(t *testing.T) {
+		client := &DefaultClient{conn: newConn()}
+		if _, err := client.QueryData(context.Background(), "SELECT 1", QueryOptions{Timezone: "Mars/Base"}); err == nil {
+			t.Error("QueryData() error = nil, want error")
+		}
+	})
+}
+
+func TestGetTables(t *testing.T) {
+	t.Run("Список таблиц", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{{
+			columns: []fakeColumnType{{name: "name", typ: "String"}},
+			data:    [][]any{{"a"}, {"b"}},
+		}}}
+		client := &DefaultClient{conn: conn}
+
+		tables, err := client.GetTables(context.Background(), "my-db")
+		if err != nil {
+			t.Fatalf("GetTables() error = %v", err)
+		}
+		if len(tables) != 2 || tables[0] != "a" || tables[1] != "b" {
+			t.Errorf("GetTables() = %v, want [a b]", tables)
+		}
+		// Имя базы передаётся параметром, а не подставляется в текст запроса
+		if strings.Contains(conn.queries[0], "my-db") {
+			t.Errorf("имя базы попало в текст запроса: %q", conn.queries[0])
+		}
+	})
+
+	t.Run("База не существует", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{
+			{columns: []fakeColumnType{{name: "name", typ: "String"}}},
+			{columns: []fakeColumnType{{name: "count()", typ: "UInt64"}}, data: [][]any{{uint64(0)}}},
+		}}
+		client := &DefaultClient{conn: conn}
+
+		_, err := client.GetTables(context.Background(), "missing")
+		if err == nil || !strings.Contains(err.Error(), "`missing`") {
+			t.Errorf("GetTables() error = %v, want ошибку об отсутствии базы", err)
+		}
+	})
+
+	t.Run("Некорректное имя", func(t *testing.T) {
+		client := &DefaultClient{conn: &fakeConn{}}
+
+		_, err := client.GetTables(context.Background(), "")
+		if !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("GetTables() error = %v, want ErrInvalidIdentifier", err)
+		}
+	})
+}
+
+func TestGetTableSchema(t *testing.T) {
+	t.Run("Структура таблицы", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{{
+			columns: []fakeColumnType{{name: "name", typ: "String"}, {name: "type", typ: "String"}, {name: "position", typ: "UInt64"}},
+			data: [][]any{
+				{"id", "UInt64", uint64(1)},
+				{"tags", "Array(String)", uint64(2)},
+			},
+		}}}
+		client := &DefaultClient{conn: conn}
+
+		columns, err := client.GetTableSchema(context.Background(), "db", "таблица.v2")
+		if err != nil {
+			t.Fatalf("GetTableSchema() error = %v", err)
+		}
+		if len(columns) != 2 || columns[1].Name != "tags" || columns[1].Position != 2 || !columns[1].IsArray {
+			t.Errorf("GetTableSchema() = %+v", columns)
+		}
+		if strings.Contains(conn.queries[0], "таблица") {
+			t.Errorf("имя таблицы попало в текст запроса: %q", conn.queries[0])
+		}
+	})
+
+	t.Run("Таблица не существует", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{{
+			columns: []fakeColumnType{{name: "name", typ: "String"}, {name: "type", typ: "String"}, {name: "position", typ: "UInt64"}},
+		}}}
+		client := &DefaultClient{conn: conn}
+
+		_, err := client.GetTableSchema(context.Background(), "db", "missing")
+		if err == nil || !strings.Contains(err.Error(), "`db`.`missing`") {
+			t.Errorf("GetTableSchema() error = %v, want ошибку об отсутствии таблицы", err)
+		}
+	})
+
+	t.Run("Некорректное имя таблицы", func(t *testing.T) {
+		client := &DefaultClient{conn: &fakeConn{}}
+
+		_, err := client.GetTableSchema(context.Background(), "db", "a\x00b")
+		if !errors.Is(err, ErrInvalidIdentifier) {
+			t.Errorf("GetTableSchema() error = %v, want ErrInvalidIdentifier", err)
+		}
+	})
+}
+
+func TestQueryDataWithParams(t *testing.T) {
+	conn := &fakeConn{responses: []*fakeRows{{
+		columns: []fakeColumnType{{name: "id", typ: "UInt64", scan: reflect.TypeOf(uint64(0))}},
+		data:    [][]any{{uint64(42)}},
+	}}}
+	client := &DefaultClient{conn: conn}
+
+	// Ошибка привязки обнаруживается до выполнения запроса
+	_, err := client.QueryDataWithParams(context.Background(), "SELECT {id:UInt64} AS id", map[string]any{"id": "x"}, QueryOptions{})
+	if err == nil || len(conn.queries) != 0 {
+		t.Fatalf("QueryDataWithParams() error = %v, выполнено запросов: %d", err, len(conn.queries))
+	}
+
+	result, err := client.QueryDataWithParams(context.Background(), "SELECT {id:UInt64} AS id", map[string]any{"id": float64(42)}, QueryOptions{})
+	if err != nil {
+		t.Fatalf("QueryDataWithParams() error = %v", err)
+	}
+	if len(result.Rows) != 1 || conn.queries[0] != "SELECT {id:UInt64} AS id" {
+		t.Errorf("QueryDataWithParams() rows = %v, запрос %q", result.Rows, conn.queries[0])
+	}
 }
@@ -0,0 +1,470 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// NumericMode 大整数和Decimal的编码方式
+type NumericMode string
+
+const (
+	// NumericNumber 输出为JSON数字，超过2^53的值在JavaScript等客户端中会丢失精度
+	NumericNumber NumericMode = "number"
+	// NumericString 64位及更宽的整数和Decimal输出为字符串，保证精度不丢失
+	NumericString NumericMode = "string"
+)
+
+// ParseNumericMode 解析数值编码方式，空串表示默认的number
+func ParseNumericMode(name string) (NumericMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "number":
+		return NumericNumber, nil
+	case "string":
+		return NumericString, nil
+	default:
+		return "", fmt.Errorf("未知的数值编码方式: %q", name)
+	}
+}
+
+// wideNumericTypes 超出JSON数字(float64)精确范围的数值类型
+var wideNumericTypes = map[string]bool{
+	"Int64": true, "UInt64": true,
+	"Int128": true, "UInt128": true,
+	"Int256": true, "UInt256": true,
+	"Decimal": true, "Decimal32": true, "Decimal64": true,
+	"Decimal128": true, "Decimal256": true,
+}
+
+// dynamicTypes 值的类型在运行时才能确定的类型，可能包含大整数
+var dynamicTypes = map[string]bool{
+	"JSON": true, "Object": true, "Dynamic": true,
+}
+
+// hasWideNumbers 类型中是否可能包含在string模式下编码为字符串的数值
+func (t *Type) hasWideNumbers() bool {
+	return t.find(func(e *Type) bool {
+		return wideNumericTypes[e.Name] || dynamicTypes[e.Name]
+	}) != nil
+}
+
+// dateTime 返回类型中第一个DateTime/DateTime64节点，不存在时返回nil
+func (t *Type) dateTime() *Type {
+	return t.find(func(e *Type) bool {
+		return e.Name == "DateTime" || e.Name == "DateTime64"
+	})
+}
+
+// decodeOptions 值转换选项
+type decodeOptions struct {
+	numeric NumericMode
+	// location DateTime值统一转换到的时区，nil表示保留列的时区
+	location *time.Location
+}
+
+// columnDecoder 按列类型将驱动扫描出的值转换为稳定的JSON表示
+type columnDecoder struct {
+	typ *Type
+	// scanType 驱动为该列提供的扫描类型
+	scanType reflect.Type
+	opts     decodeOptions
+}
+
+// newColumnDecoder 为列创建解码器，类型字符串无法解析时退化为通用转换
+func newColumnDecoder(typeName string, scanType reflect.Type, opts decodeOptions) columnDecoder {
+	typ, err := ParseType(typeName)
+	if err != nil {
+		typ = &Type{Name: typeName}
+	}
+	if scanType == nil {
+		scanType = reflect.TypeOf((*any)(nil)).Elem()
+	}
+	return columnDecoder{typ: typ, scanType: scanType, opts: opts}
+}
+
+// numbersAsStrings 该列的数值是否编码为字符串
+func (d columnDecoder) numbersAsStrings() bool {
+	return d.opts.numeric == NumericString && d.typ.hasWideNumbers()
+}
+
+// newDest 创建扫描目标指针
+func (d columnDecoder) newDest() any {
+	return reflect.New(d.scanType).Interface()
+}
+
+// resetDest 清空扫描目标，避免NULL值保留上一行的数据
+func (d columnDecoder) resetDest(dest any) {
+	v := reflect.ValueOf(dest).Elem()
+	v.Set(reflect.Zero(v.Type()))
+}
+
+// decode 转换扫描目标中的值
+func (d columnDecoder) decode(dest any) any {
+	return decodeValue(d.typ, reflect.ValueOf(dest).Elem().Interface(), d.opts)
+}
+
+// decodeValue 按ClickHouse类型转换单个值:
+// 整数为数字，128/256位整数和Decimal为精确的数字文本，Float的NaN/Inf为字符串，
+// UUID/IP/Enum为字符串，Date为"2006-01-02"，DateTime为带时区偏移的RFC3339(DateTime64保留全部小数位)，
+// Array/Tuple为数组，Map/命名Tuple/JSON为对象，NULL为null。
+// string模式下64位及更宽的整数和Decimal输出为字符串
+func decodeValue(t *Type, v any, opts decodeOptions) any {
+	v = deref(v)
+	if v == nil {
+		return nil
+	}
+
+	switch t.Name {
+	case "Nullable", "LowCardinality":
+		if len(t.Elems) == 1 {
+			return decodeValue(t.Elems[0], v, opts)
+		}
+	case "SimpleAggregateFunction":
+		if len(t.Elems) == 1 {
+			return decodeValue(t.Elems[0], v, opts)
+		}
+	case "Int8", "Int16", "Int32", "Int64", "UInt8", "UInt16", "UInt32", "UInt64":
+		return normalizeValue(v, opts)
+	case "Int128", "Int256", "UInt128", "UInt256":
+		return decodeBigInt(v, opts)
+	case "Float32", "Float64", "BFloat16":
+		return decodeFloat(v, opts)
+	case "Decimal", "Decimal32", "Decimal64", "Decimal128", "Decimal256":
+		return decodeDecimal(t, v, opts)
+	case "String":
+		return decodeString(v, opts)
+	case "FixedString":
+		if s, ok := decodeString(v, opts).(string); ok {
+			return strings.TrimRight(s, "\x00")
+		}
+	case "Date", "Date32":
+		if tm, ok := v.(time.Time); ok {
+			return tm.Format(time.DateOnly)
+		}
+	case "DateTime", "DateTime64":
+		if tm, ok := v.(time.Time); ok {
+			return formatDateTime(t, tm, opts)
+		}
+	case "Array":
+		if len(t.Elems) == 1 {
+			return decodeArray(t.Elems[0], v, opts)
+		}
+	case "Nested":
+		return decodeArray(&Type{Name: "Tuple", Elems: t.Elems, Fields: t.Fields}, v, opts)
+	case "Map":
+		if len(t.Elems) == 2 {
+			return decodeMap(t.Elems[0], t.Elems[1], v, opts)
+		}
+	case "Tuple":
+		return decodeTuple(t, v, opts)
+	case "Nothing":
+		return nil
+	}
+
+	// UUID、IPv4/IPv6、Enum、JSON、Variant、Dynamic、Geo和Interval等类型按值的Go类型转换
+	return normalizeValue(v, opts)
+}
+
+// deref 解引用指针，nil指针返回nil
+func deref(v any) any {
+	for {
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() {
+			return nil
+		}
+		if rv.Kind() != reflect.Pointer {
+			return v
+		}
+		if rv.IsNil() {
+			return nil
+		}
+		switch v.(type) {
+		case *big.Int:
+			// big.Int按指针使用
+			return v
+		}
+		v = rv.Elem().Interface()
+	}
+}
+
+// formatDateTime 按列的精度和时区格式化DateTime/DateTime64，
+// 指定了统一时区时转换到该时区
+func formatDateTime(t *Type, tm time.Time, opts decodeOptions) string {
+	switch {
+	case opts.location != nil:
+		tm = tm.In(opts.location)
+	case t.Timezone() != "":
+		if loc, err := loadLocation(t.Timezone()); err == nil {
+			tm = tm.In(loc)
+		}
+	}
+
+	layout := "2006-01-02T15:04:05"
+	if precision := t.Precision(); precision > 0 {
+		layout += "." + strings.Repeat("0", precision)
+	}
+	return tm.Format(layout + "Z07:00")
+}
+
+// locations 已加载的时区，避免每个值都读取时区数据库
+var locations sync.Map
+
+// loadLocation 按名称加载时区并缓存
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("未知的时区%q: %w", name, err)
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// decodeBigInt 转换128/256位整数为精确的数字文本
+func decodeBigInt(v any, opts decodeOptions) any {
+	switch n := v.(type) {
+	case *big.Int:
+		return opts.number(n.String())
+	case big.Int:
+		return opts.number(n.String())
+	}
+	return normalizeValue(v, opts)
+}
+
+// number 按数值编码方式输出精确的数字文本
+func (o decodeOptions) number(s string) any {
+	if o.numeric == NumericString {
+		return s
+	}
+	return json.Number(s)
+}
+
+// decodeFloat 转换浮点数，NaN和Inf无法用JSON数字表示，使用ClickHouse的文本形式
+func decodeFloat(v any, opts decodeOptions) any {
+	switch f := v.(type) {
+	case float32:
+		if s, special := specialFloat(float64(f)); special {
+			return s
+		}
+		return json.Number(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	case float64:
+		if s, special := specialFloat(f); special {
+			return s
+		}
+		return f
+	}
+	return normalizeValue(v, opts)
+}
+
+// specialFloat 返回NaN和Inf的文本形式
+func specialFloat(f float64) (string, bool) {
+	switch {
+	case math.IsNaN(f):
+		return "nan", true
+	case math.IsInf(f, 1):
+		return "inf", true
+	case math.IsInf(f, -1):
+		return "-inf", true
+	}
+	return "", false
+}
+
+// decodeDecimal 按类型中的精度输出Decimal的精确文本
+func decodeDecimal(t *Type, v any, opts decodeOptions) any {
+	d, ok := v.(decimal.Decimal)
+	if !ok {
+		return normalizeValue(v, opts)
+	}
+
+	scaleParam := t.Param(0)
+	if t.Name == "Decimal" {
+		scaleParam = t.Param(1)
+	}
+	scale, err := strconv.Atoi(scaleParam)
+	if err != nil {
+		return opts.number(d.String())
+	}
+	return opts.number(d.StringFixed(int32(scale)))
+}
+
+// decodeString 转换字符串，[]byte按原样转换为字符串
+func decodeString(v any, opts decodeOptions) any {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	}
+	return normalizeValue(v, opts)
+}
+
+// decodeArray 逐个转换数组元素
+func decodeArray(elem *Type, v any, opts decodeOptions) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return normalizeValue(v, opts)
+	}
+	result := make([]any, rv.Len())
+	for i := range result {
+		result[i] = decodeValue(elem, rv.Index(i).Interface(), opts)
+	}
+	return result
+}
+
+// decodeMap 转换Map为JSON对象，键转换为字符串
+func decodeMap(key, value *Type, v any, opts decodeOptions) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return normalizeValue(v, opts)
+	}
+	result := make(map[string]any, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		k := decodeValue(key, iter.Key().Interface(), opts)
+		result[mapKeyString(k)] = decodeValue(value, iter.Value().Interface(), opts)
+	}
+	return result
+}
+
+// decodeTuple 命名Tuple转换为对象，未命名Tuple转换为数组
+func decodeTuple(t *Type, v any, opts decodeOptions) any {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		result := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			name := mapKeyString(normalizeValue(iter.Key().Interface(), opts))
+			result[name] = decodeValue(t.field(name), iter.Value().Interface(), opts)
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		result := make([]any, rv.Len())
+		for i := range result {
+			elem := &Type{}
+			if i < len(t.Elems) {
+				elem = t.Elems[i]
+			}
+			result[i] = decodeValue(elem, rv.Index(i).Interface(), opts)
+		}
+		return result
+	}
+	return normalizeValue(v, opts)
+}
+
+// field 按字段名查找Tuple元素类型，找不到时返回空类型
+func (t *Type) field(name string) *Type {
+	for i, f := range t.Fields {
+		if f == name && i < len(t.Elems) {
+			return t.Elems[i]
+		}
+	}
+	return &Type{}
+}
+
+// mapKeyString 将转换后的键格式化为JSON对象键
+func mapKeyString(k any) string {
+	switch s := k.(type) {
+	case string:
+		return s
+	case nil:
+		return "null"
+	}
+	return fmt.Sprint(k)
+}
+
+// normalizeValue 不依赖列类型，按值的Go类型转换为稳定的JSON表示
+func normalizeValue(v any, opts decodeOptions) any {
+	v = deref(v)
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string, bool:
+		return val
+	case []byte:
+		return string(val)
+	case int:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case int64:
+		if opts.numeric == NumericString {
+			return strconv.FormatInt(val, 10)
+		}
+		return val
+	case uint:
+		return uint64(val)
+	case uint8:
+		return uint64(val)
+	case uint16:
+		return uint64(val)
+	case uint32:
+		return uint64(val)
+	case uint64:
+		if opts.numeric == NumericString {
+			return strconv.FormatUint(val, 10)
+		}
+		return val
+	case float32, float64:
+		return decodeFloat(val, opts)
+	case *big.Int:
+		return opts.number(val.String())
+	case big.Int:
+		return opts.number(val.String())
+	case decimal.Decimal:
+		return opts.number(val.String())
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case uuid.UUID:
+		return val.String()
+	case net.IP:
+		return val.String()
+	case netip.Addr:
+		return val.String()
+	case json.Number:
+		return val
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		result := make([]any, rv.Len())
+		for i := range result {
+			result[i] = normalizeValue(rv.Index(i).Interface(), opts)
+		}
+		return result
+	case reflect.Map:
+		result := make(map[string]any, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := mapKeyString(normalizeValue(iter.Key().Interface(), opts))
+			result[key] = normalizeValue(iter.Value().Interface(), opts)
+		}
+		return result
+	case reflect.String:
+		return rv.String()
+	}
+
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,230 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestDecodeValue(t *testing.T) {
+	str := "a"
+	var nullStr *string
+	bigInt, _ := new(big.Int).SetString("170141183460469231731687303715884105727", 10)
+	id := uuid.MustParse("61f0c404-5cb3-11e7-907b-a6006ad3dba0")
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		typ   string
+		value any
+		want  any
+	}{
+		{name: "Int8", typ: "Int8", value: int8(-5), want: int64(-5)},
+		{name: "Int32", typ: "Int32", value: int32(7), want: int64(7)},
+		{name: "Int64", typ: "Int64", value: int64(math.MinInt64), want: int64(math.MinInt64)},
+		{name: "UInt8", typ: "UInt8", value: uint8(255), want: uint64(255)},
+		{name: "UInt64", typ: "UInt64", value: uint64(math.MaxUint64), want: uint64(math.MaxUint64)},
+		{name: "Int128", typ: "Int128", value: bigInt, want: json.Number("170141183460469231731687303715884105727")},
+		{name: "UInt256", typ: "UInt256", value: big.NewInt(42), want: json.Number("42")},
+		{name: "Float32", typ: "Float32", value: float32(0.1), want: json.Number("0.1")},
+		{name: "Float64", typ: "Float64", value: 1.5, want: 1.5},
+		{name: "NaN", typ: "Float64", value: math.NaN(), want: "nan"},
+		{name: "+Inf", typ: "Float32", value: float32(math.Inf(1)), want: "inf"},
+		{name: "-Inf", typ: "Float64", value: math.Inf(-1), want: "-inf"},
+		{name: "Decimal(P, S)", typ: "Decimal(10, 3)", value: decimal.RequireFromString("1.5"), want: json.Number("1.500")},
+		{name: "Decimal64(S)", typ: "Decimal64(2)", value: decimal.RequireFromString("-0.1"), want: json.Number("-0.10")},
+		{name: "Bool", typ: "Bool", value: true, want: true},
+		{name: "String", typ: "String", value: "текст", want: "текст"},
+		{name: "String из байтов", typ: "String", value: []byte{0xff, 'a'}, want: "\xffa"},
+		{name: "FixedString", typ: "FixedString(4)", value: "ab\x00\x00", want: "ab"},
+		{name: "UUID", typ: "UUID", value: id, want: "61f0c404-5cb3-11e7-907b-a6006ad3dba0"},
+		{name: "IPv4", typ: "IPv4", value: net.ParseIP("10.0.0.1").To4(), want: "10.0.0.1"},
+		{name: "IPv6", typ: "IPv6", value: net.ParseIP("::1"), want: "::1"},
+		{name: "Enum8", typ: "Enum8('a' = 1)", value: "a", want: "a"},
+		{name: "Date", typ: "Date", value: ts, want: "2024-05-06"},
+		{name: "Date32", typ: "Date32", value: ts, want: "2024-05-06"},
+		{name: "DateTime", typ: "DateTime", value: ts, want: "2024-05-06T07:08:09Z"},
+		{name: "DateTime64", typ: "DateTime64(3)", value: ts.Add(5 * time.Millisecond), want: "2024-05-06T07:08:09.005Z"},
+		{name: "DateTime64 без дробной части", typ: "DateTime64(3)", value: ts, want: "2024-05-06T07:08:09.000Z"},
+		{name: "DateTime64(9)", typ: "DateTime64(9)", value: ts.Add(1), want: "2024-05-06T07:08:09.000000001Z"},
+		{name: "DateTime с часовым поясом", typ: "DateTime('Asia/Shanghai')", value: ts, want: "2024-05-06T15:08:09+08:00"},
+		{name: "DateTime64 с часовым поясом", typ: "DateTime64(2, 'America/New_York')", value: ts, want: "2024-05-06T03:08:09.00-04:00"},
+		{name: "Nullable со значением", typ: "Nullable(String)", value: &str, want: "a"},
+		{name: "Nullable NULL", typ: "Nullable(String)", value: nullStr, want: nil},
+		{name: "LowCardinality", typ: "LowCardinality(String)", value: "x", want: "x"},
+		{name: "SimpleAggregateFunction", typ: "SimpleAggregateFunction(sum, UInt64)", value: uint64(3), want: uint64(3)},
+		{
+			name:  "Array(Nullable)",
+			typ:   "Array(Nullable(Int32))",
+			value: []*int32{nil, ptr(int32(1))},
+			want:  []any{nil, int64(1)},
+		},
+		{
+			name:  "Array(Array)",
+			typ:   "Array(Array(UInt8))",
+			value: [][]uint8{{1}, {}},
+			want:  []any{[]any{uint64(1)}, []any{}},
+		},
+		{
+			name:  "Map",
+			typ:   "Map(UInt16, Decimal(5, 1))",
+			value: map[uint16]decimal.Decimal{1: decimal.RequireFromString("2")},
+			want:  map[string]any{"1": json.Number("2.0")},
+		},
+		{
+			name:  "Именованный Tuple",
+			typ:   "Tuple(id Int128, at Date)",
+			value: map[string]any{"id": big.NewInt(1), "at": ts},
+			want:  map[string]any{"id": json.Number("1"), "at": "2024-05-06"},
+		},
+		{
+			name:  "Безымянный Tuple",
+			typ:   "Tuple(String, Float32)",
+			value: []any{"a", float32(0.5)},
+			want:  []any{"a", json.Number("0.5")},
+		},
+		{
+			name:  "Nested",
+			typ:   "Nested(a UInt8, b String)",
+			value: []map[string]any{{"a": uint8(1), "b": "x"}},
+			want:  []any{map[string]any{"a": uint64(1), "b": "x"}},
+		},
+		{
+			name:  "JSON",
+			typ:   "JSON",
+			value: map[string]any{"a": []any{int32(1), "b"}, "c": map[string]any{"d": nil}},
+			want:  map[string]any{"a": []any{int64(1), "b"}, "c": map[string]any{"d": nil}},
+		},
+		{
+			name:  "Point",
+			typ:   "Point",
+			value: [2]float64{1, 2},
+			want:  []any{1.0, 2.0},
+		},
+		{name: "Nothing", typ: "Nullable(Nothing)", value: new(any), want: nil},
+		{name: "Неизвестный тип", typ: "Unknown", value: int16(3), want: int64(3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := ParseType(tt.typ)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			got := decodeValue(typ, tt.value, decodeOptions{numeric: NumericNumber})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeValue() = %#v, want %#v", got, tt.want)
+			}
+			// Результат должен сериализоваться в JSON
+			if _, err := json.Marshal(got); err != nil {
+				t.Errorf("json.Marshal() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeValueNumericString(t *testing.T) {
+	bigInt, _ := new(big.Int).SetString("-57896044618658097711785492504343953926634992332820282019728792003956564819968", 10)
+
+	tests := []struct {
+		name  string
+		typ   string
+		value any
+		want  any
+	}{
+		{name: "Int32 остаётся числом", typ: "Int32", value: int32(-1), want: int64(-1)},
+		{name: "UInt32 остаётся числом", typ: "UInt32", value: uint32(1), want: uint64(1)},
+		{name: "Float64 остаётся числом", typ: "Float64", value: 0.5, want: 0.5},
+		{name: "Int64", typ: "Int64", value: int64(math.MaxInt64), want: "9223372036854775807"},
+		{name: "UInt64", typ: "UInt64", value: uint64(math.MaxUint64), want: "18446744073709551615"},
+		{name: "Int256", typ: "Int256", value: bigInt, want: bigInt.String()},
+		{name: "Decimal128", typ: "Decimal128(4)", value: decimal.RequireFromString("12345678901234567890.5"), want: "12345678901234567890.5000"},
+		{name: "Nullable(UInt64)", typ: "Nullable(UInt64)", value: ptr(uint64(7)), want: "7"},
+		{name: "Array(UInt64)", typ: "Array(UInt64)", value: []uint64{1, 2}, want: []any{"1", "2"}},
+		{
+			name:  "Map(UInt64, Decimal)",
+			typ:   "Map(UInt64, Decimal(5, 2))",
+			value: map[uint64]decimal.Decimal{9: decimal.RequireFromString("1")},
+			want:  map[string]any{"9": "1.00"},
+		},
+		{name: "JSON", typ: "JSON", value: map[string]any{"id": int64(1)}, want: map[string]any{"id": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := ParseType(tt.typ)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			got := decodeValue(typ, tt.value, decodeOptions{numeric: NumericString})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasWideNumbers(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want bool
+	}{
+		{typ: "Int32", want: false},
+		{typ: "Float64", want: false},
+		{typ: "Array(String)", want: false},
+		{typ: "UInt64", want: true},
+		{typ: "Nullable(Decimal(10, 2))", want: true},
+		{typ: "Map(String, Array(Int128))", want: true},
+		{typ: "Tuple(a String, b UInt256)", want: true},
+		{typ: "JSON", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			typ, err := ParseType(tt.typ)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			if got := typ.hasWideNumbers(); got != tt.want {
+				t.Errorf("hasWideNumbers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumericMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    NumericMode
+		wantErr bool
+	}{
+		{name: "По умолчанию", input: "", want: NumericNumber},
+		{name: "number", input: "number", want: NumericNumber},
+		{name: "string в верхнем регистре", input: " STRING ", want: NumericString},
+		{name: "Неизвестный режим", input: "float", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNumericMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNumericMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseNumericMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,310 @@
+package clickhouse
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// compressionMethods 原生协议支持的压缩方式
+var compressionMethods = map[string]clickhouse.CompressionMethod{
+	"none": clickhouse.CompressionNone,
+	"lz4":  clickhouse.CompressionLZ4,
+	"zstd": clickhouse.CompressionZSTD,
+}
+
+// settingName 服务端设置名称
+var settingName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ParseDSN 解析与clickhouse-go兼容的DSN:
+//
+//	[clickhouse://|http://|https://][user[:password]@]host1[:port1][,host2[:port2]...][/database][?param=value&...]
+//
+// clickhouse://(或tcp://、无协议)使用原生协议，http://和https://使用HTTP接口，https://相当于secure=true。
+// 未指定端口时原生协议使用9000(secure时为9440)，HTTP使用8123，HTTPS使用8443，未指定数据库时使用default。
+// 支持的参数与clickhouse-go相同: username、password、secure、skip_verify、dial_timeout、
+// read_timeout、compress、compress_level、max_compression_buffer、block_buffer_size、
+// max_open_conns、max_idle_conns、conn_max_lifetime、connection_open_strategy和
+// client_info_product，HTTP接口还支持http_path和http_proxy，
+// 其他参数作为服务端设置随每个查询发送。用户名、密码和数据库需要百分号编码
+func ParseDSN(dsn string) (Config, error) {
+	return parseDSN(dsn, false)
+}
+
+// ParseSecureDSN 与ParseDSN相同，但总是使用安全连接，相当于DSN中指定了secure，
+// 未指定端口的地址因此使用TLS端口
+func ParseSecureDSN(dsn string) (Config, error) {
+	return parseDSN(dsn, true)
+}
+
+// parseDSN 解析DSN，secure为true时强制使用安全连接
+func parseDSN(dsn string, secure bool) (Config, error) {
+	cfg := Config{Secure: secure}
+	rest := strings.TrimSpace(dsn)
+	if rest == "" {
+		return cfg, fmt.Errorf("DSN为空")
+	}
+
+	var https bool
+	if scheme, after, ok := strings.Cut(rest, "://"); ok {
+		switch strings.ToLower(scheme) {
+		case "clickhouse", "tcp":
+		case "http":
+			cfg.Protocol = ProtocolHTTP
+		case "https":
+			cfg.Protocol, cfg.Secure, https = ProtocolHTTP, true, true
+		default:
+			return cfg, fmt.Errorf("DSN协议%q不受支持(支持: clickhouse, tcp, http, https)", scheme)
+		}
+		rest = after
+	}
+
+	rest, query, _ := strings.Cut(rest, "?")
+	if at := strings.LastIndexByte(rest, '@'); at >= 0 {
+		if err := cfg.parseUserinfo(rest[:at]); err != nil {
+			return cfg, err
+		}
+		rest = rest[at+1:]
+	}
+
+	// 默认端口取决于参数中的secure，先解析参数
+	if err := cfg.parseParams(query); err != nil {
+		return cfg, err
+	}
+	cfg.Secure = cfg.Secure || secure
+	if https && !cfg.Secure {
+		return cfg, fmt.Errorf("DSN参数secure=false不能用于https协议")
+	}
+	if cfg.Protocol != ProtocolHTTP && (cfg.HTTPPath != "" || cfg.HTTPProxy != "") {
+		return cfg, fmt.Errorf("DSN参数http_path和http_proxy只能用于HTTP接口(http://或https://)")
+	}
+	if _, err := cfg.compressionMethod(); err != nil {
+		return cfg, fmt.Errorf("DSN参数compress: %w", err)
+	}
+
+	defaultPort := "9000"
+	if cfg.Secure {
+		defaultPort = "9440"
+	}
+	if cfg.Protocol == ProtocolHTTP {
+		defaultPort = "8123"
+		if cfg.Secure {
+			defaultPort = "8443"
+		}
+	}
+	hosts, database, _ := strings.Cut(rest, "/")
+	for _, hostPort := range strings.Split(hosts, ",") {
+		addr, err := parseAddr(strings.TrimSpace(hostPort), defaultPort)
+		if err != nil {
+			return cfg, fmt.Errorf("DSN地址: %w", err)
+		}
+		cfg.Addr = append(cfg.Addr, addr)
+	}
+
+	database, err := url.PathUnescape(strings.TrimSuffix(database, "/"))
+	if err != nil {
+		return cfg, fmt.Errorf("DSN数据库名: %w", err)
+	}
+	if strings.Contains(database, "/") {
+		return cfg, fmt.Errorf("DSN数据库名%q无效: 路径只能包含数据库名", database)
+	}
+	cfg.Database = database
+	if cfg.Database == "" {
+		cfg.Database = "default" // 默认数据库
+	}
+	return cfg, nil
+}
+
+// parseUserinfo 解析DSN中的user[:password]
+func (cfg *Config) parseUserinfo(userinfo string) error {
+	username, password, _ := strings.Cut(userinfo, ":")
+	var err error
+	if cfg.Username, err = url.PathUnescape(username); err != nil {
+		return fmt.Errorf("DSN用户名: %w", err)
+	}
+	if cfg.Password, err = url.PathUnescape(password); err != nil {
+		// 错误信息中不能包含密码
+		return fmt.Errorf("DSN密码包含无效的百分号编码")
+	}
+	return nil
+}
+
+// parseParams 解析DSN的查询参数，参数不能重复
+func (cfg *Config) parseParams(query string) error {
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("DSN参数: %w", err)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		values := params[name]
+		if len(values) > 1 {
+			return fmt.Errorf("DSN参数%s重复", name)
+		}
+		if err := cfg.setParam(name, values[0]); err != nil {
+			return fmt.Errorf("DSN参数%s=%q无效: %w", name, values[0], err)
+		}
+	}
+
+	if cfg.TLS.InsecureSkipVerify && !cfg.Secure {
+		return fmt.Errorf("DSN参数skip_verify只能与secure一起使用")
+	}
+	return nil
+}
+
+// setParam 设置一个DSN参数，未知的参数作为服务端设置
+func (cfg *Config) setParam(name, value string) error {
+	var err error
+	switch name {
+	case "username":
+		cfg.Username = value
+	case "password":
+		cfg.Password = value
+	case "secure":
+		cfg.Secure, err = parseFlag(value)
+	case "skip_verify":
+		cfg.TLS.InsecureSkipVerify, err = parseFlag(value)
+	case "dial_timeout":
+		cfg.DialTimeout, err = parseTimeout(value)
+	case "read_timeout":
+		cfg.ReadTimeout, err = parseTimeout(value)
+	case "conn_max_lifetime":
+		cfg.ConnMaxLifetime, err = parseTimeout(value)
+	case "compress":
+		cfg.Compression, err = parseCompression(value)
+	case "compress_level":
+		cfg.CompressionLevel, err = parseCount(value, 0)
+	case "max_compression_buffer":
+		cfg.MaxCompressionBuffer, err = parseCount(value, 1)
+	case "max_open_conns":
+		cfg.MaxOpenConns, err = parseCount(value, 1)
+	case "max_idle_conns":
+		cfg.MaxIdleConns, err = parseCount(value, 0)
+	case "block_buffer_size":
+		var size uint64
+		if size, err = strconv.ParseUint(value, 10, 8); err == nil && size == 0 {
+			err = fmt.Errorf("必须在1到255之间")
+		}
+		cfg.BlockBufferSize = uint8(size)
+	case "connection_open_strategy":
+		cfg.ConnOpenStrategy, err = ParseConnOpenStrategy(value)
+	case "http_path":
+		if !strings.HasPrefix(value, "/") {
+			return fmt.Errorf("路径必须以/开头")
+		}
+		cfg.HTTPPath = value
+	case "http_proxy":
+		if _, err := newProxyDialer(value, nil); err != nil {
+			return err
+		}
+		cfg.HTTPProxy = value
+	case "client_info_product":
+		for _, product := range strings.Split(value, ",") {
+			if name, _, _ := strings.Cut(product, "/"); name == "" {
+				return fmt.Errorf("格式应为name/version[,name/version...]")
+			}
+			cfg.ClientInfoProducts = append(cfg.ClientInfoProducts, product)
+		}
+	case "debug":
+		// 驱动的调试日志写入stdout，会破坏stdio传输
+		return fmt.Errorf("不支持驱动调试日志")
+	default:
+		if !settingName.MatchString(name) {
+			return fmt.Errorf("未知参数，也不是合法的设置名称")
+		}
+		if cfg.Settings == nil {
+			cfg.Settings = map[string]any{}
+		}
+		cfg.Settings[name] = settingValue(value)
+	}
+	return err
+}
+
+// parseFlag 解析布尔参数，空值表示true
+func parseFlag(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parseTimeout 解析正的时长，如10s
+func parseTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("必须大于0")
+	}
+	return d, err
+}
+
+// parseCount 解析不小于minimum的整数
+func parseCount(value string, minimum int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err == nil && n < minimum {
+		err = fmt.Errorf("不能小于%d", minimum)
+	}
+	return n, err
+}
+
+// parseCompression 解析压缩方式，true表示lz4，false表示none。
+// 压缩方式是否适用于协议由Config.compressionMethod检查
+func parseCompression(value string) (string, error) {
+	method := strings.ToLower(value)
+	if on, err := strconv.ParseBool(method); err == nil {
+		if on {
+			return "lz4", nil
+		}
+		return "none", nil
+	}
+	_, native := compressionMethods[method]
+	_, httpOnly := httpCompressionMethods[method]
+	if !native && !httpOnly {
+		return "", fmt.Errorf("支持的压缩方式: lz4, zstd, none, HTTP接口还支持gzip, deflate, br")
+	}
+	return method, nil
+}
+
+// settingValue 按clickhouse-go的规则转换设置值: true和false转换为1和0，整数转换为int
+func settingValue(value string) any {
+	switch v := strings.ToLower(value); v {
+	case "true":
+		return 1
+	case "false":
+		return 0
+	default:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		return value
+	}
+}
+
+// parseAddr 解析host[:port]，未指定端口时使用defaultPort。带端口的IPv6地址需要放在方括号中
+func parseAddr(hostPort, defaultPort string) (string, error) {
+	host, port := hostPort, defaultPort
+	switch {
+	case strings.Contains(hostPort, "]:"), strings.Count(hostPort, ":") == 1:
+		var err error
+		if host, port, err = net.SplitHostPort(hostPort); err != nil {
+			return "", err
+		}
+	case strings.HasPrefix(hostPort, "["):
+		host = strings.TrimSuffix(strings.TrimPrefix(hostPort, "["), "]")
+	}
+
+	if host == "" {
+		return "", fmt.Errorf("地址%q缺少主机名", hostPort)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+		return "", fmt.Errorf("地址%q的端口无效", hostPort)
+	}
+	return net.JoinHostPort(host, port), nil
+}
@@ -0,0 +1,168 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// ConnOpenStrategy 配置了多个地址时选择连接地址的策略
+type ConnOpenStrategy string
+
+const (
+	// ConnOpenInOrder 按配置顺序，前面的地址不可用时使用后面的地址
+	ConnOpenInOrder ConnOpenStrategy = "in_order"
+	// ConnOpenRoundRobin 新连接轮流使用各个地址
+	ConnOpenRoundRobin ConnOpenStrategy = "round_robin"
+	// ConnOpenRandom 新连接随机选择地址
+	ConnOpenRandom ConnOpenStrategy = "random"
+)
+
+// ParseConnOpenStrategy 解析连接策略名称，空串表示ConnOpenInOrder
+func ParseConnOpenStrategy(name string) (ConnOpenStrategy, error) {
+	switch strategy := ConnOpenStrategy(strings.ToLower(strings.TrimSpace(name))); strategy {
+	case "":
+		return ConnOpenInOrder, nil
+	case ConnOpenInOrder, ConnOpenRoundRobin, ConnOpenRandom:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("未知的连接策略: %q(支持: %s, %s, %s)", name, ConnOpenInOrder, ConnOpenRoundRobin, ConnOpenRandom)
+}
+
+// endpointPool 多个ClickHouse地址及其健康状态。建立新连接时按策略排列地址，
+// 健康的地址优先，不健康的地址排在最后，所有地址都不健康时仍会逐个尝试
+type endpointPool struct {
+	addrs    []string
+	strategy ConnOpenStrategy
+	// probe 健康检查，默认建立TCP连接
+	probe func(ctx context.Context, addr string) error
+
+	mu sync.RWMutex
+	// failures 不健康的地址及最近一次的错误
+	failures map[string]error
+}
+
+// newEndpointPool 创建地址池，初始时所有地址都视为健康
+func newEndpointPool(addrs []string, strategy ConnOpenStrategy, dialTimeout time.Duration) *endpointPool {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	return &endpointPool{
+		addrs:    addrs,
+		strategy: strategy,
+		failures: map[string]error{},
+		probe: func(ctx context.Context, addr string) error {
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		},
+	}
+}
+
+// order 按策略和健康状态返回第connID个连接尝试地址的顺序
+func (p *endpointPool) order(connID int) []string {
+	n := len(p.addrs)
+	ordered := make([]string, n)
+	switch p.strategy {
+	case ConnOpenRoundRobin:
+		for i := range ordered {
+			ordered[i] = p.addrs[(connID+i)%n]
+		}
+	case ConnOpenRandom:
+		for i, j := range rand.Perm(n) {
+			ordered[i] = p.addrs[j]
+		}
+	default:
+		copy(ordered, p.addrs)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	healthy := ordered[:0:0]
+	var unhealthy []string
+	for _, addr := range ordered {
+		if _, failed := p.failures[addr]; failed {
+			unhealthy = append(unhealthy, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// dialStrategy 实现clickhouse.Options.DialStrategy: 按order的顺序尝试各地址，
+// 并根据结果更新地址的健康状态
+func (p *endpointPool) dialStrategy(ctx context.Context, connID int, opt *clickhouse.Options, dial clickhouse.Dial) (clickhouse.DialResult, error) {
+	var (
+		result clickhouse.DialResult
+		err    error
+	)
+	for _, addr := range p.order(connID) {
+		result, err = dial(ctx, addr, opt)
+		if ctx.Err() != nil {
+			return result, err
+		}
+		p.mark(addr, err)
+		if err == nil {
+			return result, nil
+		}
+	}
+	if err == nil {
+		err = clickhouse.ErrAcquireConnNoAddress
+	}
+	return result, err
+}
+
+// mark 记录地址的健康状态，err为nil表示健康。状态变化时写日志
+func (p *endpointPool) mark(addr string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, failed := p.failures[addr]
+	switch {
+	case err != nil:
+		if !failed {
+			slog.Warn("ClickHouse地址不可用", "addr", addr, "err", err)
+		}
+		p.failures[addr] = err
+	case failed:
+		slog.Info("ClickHouse地址已恢复", "addr", addr)
+		delete(p.failures, addr)
+	}
+}
+
+// check 检查所有地址并更新健康状态
+func (p *endpointPool) check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, addr := range p.addrs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.probe(ctx, addr)
+			if ctx.Err() == nil {
+				p.mark(addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// run 每隔interval在后台检查一次所有地址，直到ctx被取消
+func (p *endpointPool) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.check(ctx)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestParseConnOpenStrategy(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ConnOpenStrategy
+		wantErr bool
+	}{
+		{"", ConnOpenInOrder, false},
+		{"in_order", ConnOpenInOrder, false},
+		{"Round_Robin", ConnOpenRoundRobin, false},
+		{" random ", ConnOpenRandom, false},
+		{"failover", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseConnOpenStrategy(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseConnOpenStrategy(%q) = %q, %v, ожидалось %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestEndpointPoolOrder(t *testing.T) {
+	addrs := []string{"ch1:9000", "ch2:9000", "ch3:9000"}
+
+	t.Run("По порядку", func(t *testing.T) {
+		p := newEndpointPool(addrs, ConnOpenInOrder, time.Second)
+		if got := p.order(5); !reflect.DeepEqual(got, addrs) {
+			t.Errorf("order() = %v", got)
+		}
+	})
+
+	t.Run("По кругу", func(t *testing.T) {
+		p := newEndpointPool(addrs, ConnOpenRoundRobin, time.Second)
+		want := []string{"ch2:9000", "ch3:9000", "ch1:9000"}
+		if got := p.order(1); !reflect.DeepEqual(got, want) {
+			t.Errorf("order(1) = %v, ожидалось %v", got, want)
+		}
+	})
+
+	t.Run("Случайно", func(t *testing.T) {
+		p := newEndpointPool(addrs, ConnOpenRandom, time.Second)
+		got := p.order(0)
+		slices.Sort(got)
+		if !reflect.DeepEqual(got, addrs) {
+			t.Errorf("order() = %v, ожидались все адреса", got)
+		}
+	})
+
+	t.Run("Недоступные адреса в конце", func(t *testing.T) {
+		p := newEndpointPool(addrs, ConnOpenInOrder, time.Second)
+		p.mark("ch1:9000", errors.New("connection refused"))
+		want := []string{"ch2:9000", "ch3:9000", "ch1:9000"}
+		if got := p.order(0); !reflect.DeepEqual(got, want) {
+			t.Errorf("order() = %v, ожидалось %v", got, want)
+		}
+
+		p.mark("ch1:9000", nil)
+		if got := p.order(0); !reflect.DeepEqual(got, addrs) {
+			t.Errorf("order() после восстановления = %v", got)
+		}
+	})
+}
+
+func TestEndpointPoolDialStrategy(t *testing.T) {
+	p := newEndpointPool([]string{"ch1:9000", "ch2:9000"}, ConnOpenInOrder, time.Second)
+
+	var dialed []string
+	down := map[string]bool{"ch1:9000": true}
+	dial := func(ctx context.Context, addr string, opt *clickhouse.Options) (clickhouse.DialResult, error) {
+		dialed = append(dialed, addr)
+		if down[addr] {
+			return clickhouse.DialResult{}, errors.New("connection refused")
+		}
+		return clickhouse.DialResult{}, nil
+	}
+
+	// Первый адрес недоступен - соединение с вторым, первый помечается
+	if _, err := p.dialStrategy(context.Background(), 1, &clickhouse.Options{}, dial); err != nil {
+		t.Fatalf("dialStrategy() error = %v", err)
+	}
+	if !reflect.DeepEqual(dialed, []string{"ch1:9000", "ch2:9000"}) {
+		t.Errorf("dialStrategy() адреса = %v", dialed)
+	}
+
+	// Следующее соединение сначала пробует доступный адрес
+	dialed = nil
+	if _, err := p.dialStrategy(context.Background(), 2, &clickhouse.Options{}, dial); err != nil {
+		t.Fatalf("dialStrategy() error = %v", err)
+	}
+	if !reflect.DeepEqual(dialed, []string{"ch2:9000"}) {
+		t.Errorf("dialStrategy() адреса = %v", dialed)
+	}
+
+	// Все адреса недоступны - пробуются все, возвращается последняя ошибка
+	down["ch2:9000"] = true
+	dialed = nil
+	if _, err := p.dialStrategy(context.Background(), 3, &clickhouse.Options{}, dial); err == nil || len(dialed) != 2 {
+		t.Errorf("dialStrategy() error = %v, адреса = %v", err, dialed)
+	}
+}
+
+func TestEndpointPoolCheck(t *testing.T) {
+	p := newEndpointPool([]string{"ch1:9000", "ch2:9000"}, ConnOpenInOrder, time.Second)
+	p.probe = func(ctx context.Context, addr string) error {
+		if addr == "ch1:9000" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	p.mark("ch2:9000", errors.New("connection refused"))
+
+	p.check(context.Background())
+
+	want := []string{"ch2:9000", "ch1:9000"}
+	if got := p.order(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("order() после проверки = %v, ожидалось %v", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package clickhouse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// errorNames 常见ClickHouse错误码的名称，与服务端ErrorCodes.cpp一致
+var errorNames = map[int32]string{
+	3:   "UNEXPECTED_END_OF_FILE",
+	6:   "CANNOT_PARSE_TEXT",
+	8:   "THERE_IS_NO_COLUMN",
+	10:  "NOT_FOUND_COLUMN_IN_BLOCK",
+	16:  "NO_SUCH_COLUMN_IN_TABLE",
+	27:  "CANNOT_PARSE_INPUT_ASSERTION_FAILED",
+	32:  "ATTEMPT_TO_READ_AFTER_EOF",
+	36:  "BAD_ARGUMENTS",
+	42:  "NUMBER_OF_ARGUMENTS_DOESNT_MATCH",
+	43:  "ILLEGAL_TYPE_OF_ARGUMENT",
+	44:  "ILLEGAL_COLUMN",
+	46:  "UNKNOWN_FUNCTION",
+	47:  "UNKNOWN_IDENTIFIER",
+	48:  "NOT_IMPLEMENTED",
+	49:  "LOGICAL_ERROR",
+	53:  "TYPE_MISMATCH",
+	60:  "UNKNOWN_TABLE",
+	62:  "SYNTAX_ERROR",
+	70:  "CANNOT_CONVERT_TYPE",
+	81:  "UNKNOWN_DATABASE",
+	115: "UNKNOWN_SETTING",
+	158: "TOO_MANY_ROWS",
+	159: "TIMEOUT_EXCEEDED",
+	160: "TOO_SLOW",
+	164: "READONLY",
+	167: "TOO_DEEP_AST",
+	168: "TOO_BIG_AST",
+	179: "MULTIPLE_EXPRESSIONS_FOR_ALIAS",
+	184: "ILLEGAL_AGGREGATION",
+	192: "UNKNOWN_USER",
+	194: "REQUIRED_PASSWORD",
+	198: "DNS_ERROR",
+	202: "TOO_MANY_SIMULTANEOUS_QUERIES",
+	209: "SOCKET_TIMEOUT",
+	210: "NETWORK_ERROR",
+	215: "NOT_AN_AGGREGATE",
+	241: "MEMORY_LIMIT_EXCEEDED",
+	242: "TABLE_IS_READ_ONLY",
+	279: "ALL_CONNECTION_TRIES_FAILED",
+	285: "TOO_FEW_LIVE_REPLICAS",
+	290: "LIMIT_EXCEEDED",
+	306: "TOO_DEEP_RECURSION",
+	307: "TOO_MANY_BYTES",
+	352: "AMBIGUOUS_COLUMN_NAME",
+	386: "NO_COMMON_TYPE",
+	394: "QUERY_WAS_CANCELLED",
+	396: "TOO_MANY_ROWS_OR_BYTES",
+	452: "SETTING_CONSTRAINT_VIOLATION",
+	456: "UNKNOWN_QUERY_PARAMETER",
+	457: "BAD_QUERY_PARAMETER",
+	497: "ACCESS_DENIED",
+	516: "AUTHENTICATION_FAILED",
+	519: "NO_REMOTE_SHARD_AVAILABLE",
+}
+
+// trailingErrorName 部分服务端版本在错误信息末尾附带的错误码名称，如"... (UNKNOWN_TABLE)"
+var trailingErrorName = regexp.MustCompile(`\(([A-Z][A-Z0-9_]+)\)\s*(?:\(version [^)]*\))?\s*$`)
+
+// ServerError ClickHouse服务端返回的异常
+type ServerError struct {
+	// Code ClickHouse错误码
+	Code int32 `json:"code"`
+	// Name 错误码名称，如UNKNOWN_TABLE，未知的错误码为空
+	Name string `json:"name,omitempty"`
+	// Message 服务端返回的错误信息
+	Message string `json:"message"`
+	// StackTrace 服务端的调用栈
+	StackTrace string `json:"stack_trace,omitempty"`
+
+	err error
+}
+
+// Error 返回包含错误码和名称的错误信息
+func (e *ServerError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("ClickHouse错误%d(%s): %s", e.Code, e.Name, e.Message)
+	}
+	return fmt.Sprintf("ClickHouse错误%d: %s", e.Code, e.Message)
+}
+
+// Unwrap 返回驱动的原始错误
+func (e *ServerError) Unwrap() error {
+	return e.err
+}
+
+// errorName 返回错误码的名称，不在已知列表中时取错误信息末尾的名称
+func errorName(code int32, message string) string {
+	if name, ok := errorNames[code]; ok {
+		return name
+	}
+	if m := trailingErrorName.FindStringSubmatch(message); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// serverError 将驱动返回的服务端异常转换为*ServerError，其他错误原样返回
+func serverError(err error) error {
+	var exception *clickhouse.Exception
+	if !errors.As(err, &exception) {
+		return err
+	}
+	var wrapped *ServerError
+	if errors.As(err, &wrapped) {
+		return err
+	}
+	return &ServerError{
+		Code:       exception.Code,
+		Name:       errorName(exception.Code, exception.Message),
+		Message:    exception.Message,
+		StackTrace: exception.StackTrace,
+		err:        err,
+	}
+}
@@ -0,0 +1,93 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestServerError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int32
+		wantName string
+	}{
+		{
+			name:     "Известный код",
+			err:      &clickhouse.Exception{Code: 60, Name: "DB::Exception", Message: "Table default.t does not exist"},
+			wantCode: 60,
+			wantName: "UNKNOWN_TABLE",
+		},
+		{
+			name:     "Обёрнутое исключение",
+			err:      fmt.Errorf("read: %w", &clickhouse.Exception{Code: 241, Message: "Memory limit (total) exceeded"}),
+			wantCode: 241,
+			wantName: "MEMORY_LIMIT_EXCEEDED",
+		},
+		{
+			name:     "Имя из текста ошибки",
+			err:      &clickhouse.Exception{Code: 999, Message: "Something went wrong (SOME_NEW_ERROR) (version 24.8.1.1)"},
+			wantCode: 999,
+			wantName: "SOME_NEW_ERROR",
+		},
+		{
+			name:     "Неизвестный код",
+			err:      &clickhouse.Exception{Code: 999, Message: "Something went wrong"},
+			wantCode: 999,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := serverError(tt.err)
+			var serverErr *ServerError
+			if !errors.As(err, &serverErr) {
+				t.Fatalf("serverError() = %v, ожидалась ServerError", err)
+			}
+			if serverErr.Code != tt.wantCode || serverErr.Name != tt.wantName {
+				t.Errorf("serverError() = %d %q, ожидалось %d %q", serverErr.Code, serverErr.Name, tt.wantCode, tt.wantName)
+			}
+			var exception *clickhouse.Exception
+			if !errors.As(err, &exception) {
+				t.Errorf("serverError() не сохраняет исходное исключение")
+			}
+			if serverError(err) != err {
+				t.Errorf("serverError() повторно оборачивает ошибку")
+			}
+		})
+	}
+
+	t.Run("Прочие ошибки не меняются", func(t *testing.T) {
+		err := errors.New("connection refused")
+		if serverError(err) != err {
+			t.Errorf("serverError() изменил ошибку %v", err)
+		}
+	})
+}
+
+func TestQueryDataServerError(t *testing.T) {
+	conn := &fakeConn{responses: []*fakeRows{{err: &clickhouse.Exception{
+		Code:       60,
+		Name:       "DB::Exception",
+		Message:    "Table default.missing does not exist",
+		StackTrace: "0. DB::Exception::Exception()",
+	}}}}
+	client := &DefaultClient{conn: conn}
+
+	_, err := client.QueryData(context.Background(), "SELECT * FROM missing", QueryOptions{})
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) {
+		t.Fatalf("QueryData() error = %v, ожидалась ServerError", err)
+	}
+	if serverErr.Name != "UNKNOWN_TABLE" || serverErr.StackTrace == "" {
+		t.Errorf("QueryData() error = %+v", serverErr)
+	}
+	if !strings.HasPrefix(err.Error(), "查询执行失败: ClickHouse错误60(UNKNOWN_TABLE)") {
+		t.Errorf("QueryData() error = %q", err)
+	}
+}
@@ -0,0 +1,158 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExplainKind EXPLAIN的类别
+type ExplainKind string
+
+const (
+	// ExplainAST 查询的抽象语法树，graph=1时输出DOT
+	ExplainAST ExplainKind = "AST"
+	// ExplainSyntax 语法优化后的查询
+	ExplainSyntax ExplainKind = "SYNTAX"
+	// ExplainQueryTree 新分析器的查询树
+	ExplainQueryTree ExplainKind = "QUERY TREE"
+	// ExplainPlan 查询计划
+	ExplainPlan ExplainKind = "PLAN"
+	// ExplainPipeline 执行管道，graph=1时输出DOT
+	ExplainPipeline ExplainKind = "PIPELINE"
+	// ExplainEstimate 按表估算读取的数据片段、行和标记数
+	ExplainEstimate ExplainKind = "ESTIMATE"
+	// ExplainTableOverride 表函数的表覆盖定义
+	ExplainTableOverride ExplainKind = "TABLE OVERRIDE"
+)
+
+// explainOptions 各EXPLAIN类别支持的选项
+var explainOptions = map[ExplainKind][]string{
+	ExplainAST:           {"graph", "optimize"},
+	ExplainSyntax:        {"oneline", "run_query_tree_passes", "query_tree_passes"},
+	ExplainQueryTree:     {"run_passes", "dump_passes", "passes", "dump_tree", "dump_ast"},
+	ExplainPlan:          {"header", "description", "indexes", "projections", "actions", "json", "sorting", "distributed"},
+	ExplainPipeline:      {"header", "graph", "compact"},
+	ExplainEstimate:      nil,
+	ExplainTableOverride: nil,
+}
+
+// explainKinds 所有EXPLAIN类别，按文档顺序排列
+var explainKinds = []ExplainKind{
+	ExplainAST, ExplainSyntax, ExplainQueryTree, ExplainPlan, ExplainPipeline, ExplainEstimate, ExplainTableOverride,
+}
+
+// ParseExplainKind 解析EXPLAIN类别，不区分大小写，下划线等同于空格，空串表示PLAN
+func ParseExplainKind(name string) (ExplainKind, error) {
+	name = strings.ToUpper(strings.Join(strings.Fields(strings.ReplaceAll(name, "_", " ")), " "))
+	if name == "" {
+		return ExplainPlan, nil
+	}
+	for _, kind := range explainKinds {
+		if string(kind) == name {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("未知的EXPLAIN类别: %q", name)
+}
+
+// ExplainKindNames 返回所有EXPLAIN类别名称
+func ExplainKindNames() []string {
+	names := make([]string, len(explainKinds))
+	for i, kind := range explainKinds {
+		names[i] = string(kind)
+	}
+	return names
+}
+
+// ExplainOptions 包含EXPLAIN的执行选项
+type ExplainOptions struct {
+	// Kind EXPLAIN类别，为空时为PLAN
+	Kind ExplainKind
+	// Settings EXPLAIN的选项，如indexes、actions、graph，值为布尔值或整数
+	Settings map[string]any
+	// Params 绑定到查询中{name:Type}占位符的参数值
+	Params map[string]any
+}
+
+// Explain 对查询执行EXPLAIN，不执行查询本身
+func (c *DefaultClient) Explain(ctx context.Context, query string, opts ExplainOptions) (QueryResult, error) {
+	statement, err := buildExplain(query, opts)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	return c.QueryData(ctx, statement, QueryOptions{Params: opts.Params})
+}
+
+// buildExplain 构造EXPLAIN语句，检查选项是否适用于该类别
+func buildExplain(query string, opts ExplainOptions) (string, error) {
+	kind := opts.Kind
+	if kind == "" {
+		kind = ExplainPlan
+	}
+	allowed, ok := explainOptions[kind]
+	if !ok {
+		return "", fmt.Errorf("未知的EXPLAIN类别: %q", kind)
+	}
+
+	statements := splitStatements(normalizeQuery(query))
+	if len(statements) != 1 {
+		return "", fmt.Errorf("EXPLAIN只能用于单条语句，实际为%d条", len(statements))
+	}
+	if statements[0].tokens[0].IsKeyword("EXPLAIN") {
+		return "", fmt.Errorf("查询已经是EXPLAIN语句，请只传入要分析的查询")
+	}
+
+	names := make([]string, 0, len(opts.Settings))
+	for name := range opts.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	settings := make([]string, len(names))
+	for i, name := range names {
+		if !slices.Contains(allowed, name) {
+			if len(allowed) == 0 {
+				return "", fmt.Errorf("EXPLAIN %s不支持选项", kind)
+			}
+			return "", fmt.Errorf("EXPLAIN %s不支持选项%s(支持: %s)", kind, name, strings.Join(allowed, ", "))
+		}
+		value, err := explainSettingValue(opts.Settings[name])
+		if err != nil {
+			return "", fmt.Errorf("EXPLAIN选项%s: %w", name, err)
+		}
+		settings[i] = name + " = " + value
+	}
+
+	statement := "EXPLAIN " + string(kind)
+	if len(settings) > 0 {
+		statement += " " + strings.Join(settings, ", ")
+	}
+	return statement + " " + statements[0].text, nil
+}
+
+// explainSettingValue 将EXPLAIN选项的值转换为文本，布尔值转换为1或0
+func explainSettingValue(v any) (string, error) {
+	switch val := v.(type) {
+	case bool:
+		if val {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		if val < 0 || val != math.Trunc(val) {
+			return "", fmt.Errorf("值必须是非负整数")
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case int:
+		if val < 0 {
+			return "", fmt.Errorf("值必须是非负整数")
+		}
+		return strconv.Itoa(val), nil
+	}
+	return "", fmt.Errorf("值必须是布尔值或整数")
+}
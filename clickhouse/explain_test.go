@@ -0,0 +1,121 @@
+package clickhouse
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseExplainKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ExplainKind
+		wantErr bool
+	}{
+		{"", ExplainPlan, false},
+		{"ast", ExplainAST, false},
+		{"Pipeline", ExplainPipeline, false},
+		{"query_tree", ExplainQueryTree, false},
+		{" QUERY   TREE ", ExplainQueryTree, false},
+		{"table override", ExplainTableOverride, false},
+		{"indexes", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExplainKind(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseExplainKind(%q) = %q, %v, ожидалось %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestBuildExplain(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		opts    ExplainOptions
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "PLAN по умолчанию",
+			query: "SELECT 1;",
+			want:  "EXPLAIN PLAN SELECT 1",
+		},
+		{
+			name:  "Опции сортируются, булевы значения становятся 1/0",
+			query: "SELECT * FROM t",
+			opts:  ExplainOptions{Kind: ExplainPlan, Settings: map[string]any{"indexes": true, "actions": false, "json": float64(1)}},
+			want:  "EXPLAIN PLAN actions = 0, indexes = 1, json = 1 SELECT * FROM t",
+		},
+		{
+			name:  "Граф конвейера",
+			query: "SELECT count() FROM t",
+			opts:  ExplainOptions{Kind: ExplainPipeline, Settings: map[string]any{"graph": true}},
+			want:  "EXPLAIN PIPELINE graph = 1 SELECT count() FROM t",
+		},
+		{
+			name:    "Опция другого вида",
+			query:   "SELECT 1",
+			opts:    ExplainOptions{Kind: ExplainPipeline, Settings: map[string]any{"indexes": true}},
+			wantErr: "不支持选项indexes",
+		},
+		{
+			name:    "ESTIMATE без опций",
+			query:   "SELECT 1",
+			opts:    ExplainOptions{Kind: ExplainEstimate, Settings: map[string]any{"graph": true}},
+			wantErr: "EXPLAIN ESTIMATE不支持选项",
+		},
+		{
+			name:    "Недопустимое значение опции",
+			query:   "SELECT 1",
+			opts:    ExplainOptions{Settings: map[string]any{"indexes": "yes"}},
+			wantErr: "EXPLAIN选项indexes",
+		},
+		{
+			name:    "Несколько запросов",
+			query:   "SELECT 1; SELECT 2",
+			wantErr: "单条语句",
+		},
+		{
+			name:    "Уже EXPLAIN",
+			query:   "EXPLAIN SELECT 1",
+			wantErr: "已经是EXPLAIN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildExplain(tt.query, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("buildExplain() error = %v, ожидалось %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildExplain() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("buildExplain() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	conn := &fakeConn{responses: []*fakeRows{explainRows("digraph", "{", "}")}}
+	client := &DefaultClient{conn: conn}
+
+	result, err := client.Explain(context.Background(), "SELECT {id:UInt64}", ExplainOptions{
+		Kind:     ExplainPipeline,
+		Settings: map[string]any{"graph": true},
+		Params:   map[string]any{"id": float64(1)},
+	})
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	if len(result.Rows) != 3 || conn.queries[0] != "EXPLAIN PIPELINE graph = 1 SELECT {id:UInt64}" {
+		t.Errorf("Explain() rows = %v, запрос %q", result.Rows, conn.queries[0])
+	}
+}
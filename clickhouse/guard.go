@@ -0,0 +1,254 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CostLimits 执行查询前按EXPLAIN ESTIMATE检查的成本上限，0表示不限制
+type CostLimits struct {
+	// MaxRows 估算读取的最大行数
+	MaxRows uint64 `json:"max_rows,omitempty"`
+	// MaxParts 估算读取的最大数据片段数
+	MaxParts uint64 `json:"max_parts,omitempty"`
+	// MaxBytes 估算读取的最大字节数(按表的平均行大小估算，为压缩后的大小)
+	MaxBytes uint64 `json:"max_bytes,omitempty"`
+}
+
+// enabled 是否设置了任一上限
+func (l CostLimits) enabled() bool {
+	return l.MaxRows > 0 || l.MaxParts > 0 || l.MaxBytes > 0
+}
+
+// TableEstimate 单个表的读取量估算
+type TableEstimate struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+	Parts    uint64 `json:"parts"`
+	Rows     uint64 `json:"rows"`
+	Marks    uint64 `json:"marks"`
+	// Bytes 按表的平均行大小估算的读取字节数，未估算时为0
+	Bytes uint64 `json:"bytes,omitempty"`
+}
+
+// CostEstimate 查询的读取量估算，为所有表的合计
+type CostEstimate struct {
+	Tables []TableEstimate `json:"tables"`
+	Parts  uint64          `json:"parts"`
+	Rows   uint64          `json:"rows"`
+	Marks  uint64          `json:"marks"`
+	Bytes  uint64          `json:"bytes,omitempty"`
+}
+
+// CostLimitError 查询的估算成本超出上限，查询未执行
+type CostLimitError struct {
+	Estimate CostEstimate
+	Limits   CostLimits
+	// Exceeded 超出的上限说明
+	Exceeded []string
+	// Suggestions 降低查询成本的建议
+	Suggestions []string
+}
+
+// Error 返回包含估算结果和建议的错误信息
+func (e *CostLimitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "查询被拒绝，估算成本超出限制: %s", strings.Join(e.Exceeded, "; "))
+	b.WriteString("\n估算:")
+	for _, t := range e.Estimate.Tables {
+		fmt.Fprintf(&b, "\n  %s.%s: 数据片段%d个，行%d，标记%d", t.Database, t.Table, t.Parts, t.Rows, t.Marks)
+		if t.Bytes > 0 {
+			fmt.Fprintf(&b, "，约%s", formatBytes(t.Bytes))
+		}
+	}
+	if len(e.Suggestions) > 0 {
+		b.WriteString("\n建议:")
+		for _, s := range e.Suggestions {
+			b.WriteString("\n  - ")
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
+
+// formatBytes 以二进制单位格式化字节数
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGTP"[exp])
+}
+
+// checkCost 对SELECT查询执行EXPLAIN ESTIMATE，估算成本超出上限时返回CostLimitError。
+// ctx应包含查询的参数和设置
+func (c *DefaultClient) checkCost(ctx context.Context, query string) error {
+	statements := splitStatements(query)
+	if len(statements) != 1 || !isWrappable(statements[0]) {
+		return nil
+	}
+
+	estimate, err := c.estimate(ctx, query)
+	if err != nil {
+		return fmt.Errorf("估算查询成本失败: %w", serverError(err))
+	}
+
+	limits := c.costLimits
+	var exceeded []string
+	if limits.MaxRows > 0 && estimate.Rows > limits.MaxRows {
+		exceeded = append(exceeded, fmt.Sprintf("读取行数%d超过上限%d", estimate.Rows, limits.MaxRows))
+	}
+	if limits.MaxParts > 0 && estimate.Parts > limits.MaxParts {
+		exceeded = append(exceeded, fmt.Sprintf("数据片段数%d超过上限%d", estimate.Parts, limits.MaxParts))
+	}
+	if limits.MaxBytes > 0 && estimate.Bytes > limits.MaxBytes {
+		exceeded = append(exceeded, fmt.Sprintf("读取量%s超过上限%s", formatBytes(estimate.Bytes), formatBytes(limits.MaxBytes)))
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	return &CostLimitError{
+		Estimate:    estimate,
+		Limits:      limits,
+		Exceeded:    exceeded,
+		Suggestions: c.suggest(ctx, query),
+	}
+}
+
+// estimate 执行EXPLAIN ESTIMATE，设置了字节上限时按表的平均行大小估算字节数
+func (c *DefaultClient) estimate(ctx context.Context, query string) (CostEstimate, error) {
+	rows, err := c.query(ctx, "EXPLAIN ESTIMATE "+query)
+	if err != nil {
+		return CostEstimate{}, err
+	}
+	defer rows.Close()
+
+	var estimate CostEstimate
+	for rows.Next() {
+		var t TableEstimate
+		if err := rows.Scan(&t.Database, &t.Table, &t.Parts, &t.Rows, &t.Marks); err != nil {
+			return CostEstimate{}, err
+		}
+		estimate.Tables = append(estimate.Tables, t)
+	}
+	if err := rows.Err(); err != nil {
+		return CostEstimate{}, err
+	}
+
+	for i := range estimate.Tables {
+		t := &estimate.Tables[i]
+		if c.costLimits.MaxBytes > 0 {
+			t.Bytes = c.tableBytes(ctx, t.Database, t.Table, t.Rows)
+		}
+		estimate.Parts += t.Parts
+		estimate.Rows += t.Rows
+		estimate.Marks += t.Marks
+		estimate.Bytes += t.Bytes
+	}
+	return estimate, nil
+}
+
+// tableBytes 按system.tables中的平均行大小估算读取rows行的字节数，无法估算时返回0
+func (c *DefaultClient) tableBytes(ctx context.Context, database, table string, rows uint64) uint64 {
+	var totalRows, totalBytes *uint64
+	err := c.queryRow(c.withParameters(ctx, textParams{
+		"database": database,
+		"table":    table,
+	}.escaped()), "SELECT total_rows, total_bytes FROM system.tables WHERE database = {database:String} AND name = {table:String}",
+		&totalRows, &totalBytes)
+	if err != nil || totalRows == nil || totalBytes == nil || *totalRows == 0 {
+		return 0
+	}
+	return uint64(float64(rows) * float64(*totalBytes) / float64(*totalRows))
+}
+
+// suggest 根据EXPLAIN indexes=1的结果给出降低查询成本的建议
+func (c *DefaultClient) suggest(ctx context.Context, query string) []string {
+	var suggestions []string
+
+	if plan, err := c.explainIndexes(ctx, query); err == nil {
+		for _, idx := range parseIndexUsage(plan) {
+			if idx.condition != "true" || len(idx.keys) == 0 {
+				continue
+			}
+			keys := strings.Join(idx.keys, ", ")
+			switch idx.kind {
+			case "Partition":
+				suggestions = append(suggestions, fmt.Sprintf("按分区键(%s)添加过滤条件以裁剪分区", keys))
+			case "PrimaryKey":
+				suggestions = append(suggestions, fmt.Sprintf("在WHERE中按主键列(%s)过滤以利用主键索引", keys))
+			}
+		}
+	}
+
+	return append(suggestions,
+		"缩小时间范围或添加更有选择性的过滤条件",
+		"只选择需要的列，或对大表使用SAMPLE子句、预聚合的表",
+	)
+}
+
+// explainIndexes 执行EXPLAIN indexes=1并返回计划的各行
+func (c *DefaultClient) explainIndexes(ctx context.Context, query string) ([]string, error) {
+	rows, err := c.query(ctx, "EXPLAIN indexes = 1 "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, err
+		}
+		plan = append(plan, line)
+	}
+	return plan, rows.Err()
+}
+
+// indexUsage EXPLAIN indexes=1中一个索引的使用情况
+type indexUsage struct {
+	// kind 索引类别: MinMax、Partition、PrimaryKey或Skip
+	kind string
+	// keys 索引的键表达式
+	keys []string
+	// condition 用于索引的条件，"true"表示没有可用的条件
+	condition string
+}
+
+// parseIndexUsage 从EXPLAIN indexes=1的输出中提取各索引的键和条件
+func parseIndexUsage(plan []string) []indexUsage {
+	var (
+		result  []indexUsage
+		current *indexUsage
+		inKeys  bool
+	)
+	for _, line := range plan {
+		text := strings.TrimSpace(line)
+		switch {
+		case text == "MinMax" || text == "Partition" || text == "PrimaryKey" || text == "Skip":
+			result = append(result, indexUsage{kind: text})
+			current = &result[len(result)-1]
+			inKeys = false
+		case current == nil:
+			continue
+		case text == "Keys:":
+			inKeys = true
+		case strings.HasPrefix(text, "Condition:"):
+			current.condition = strings.TrimSpace(strings.TrimPrefix(text, "Condition:"))
+			inKeys = false
+		case strings.Contains(text, ":"):
+			inKeys = false
+		case inKeys:
+			current.keys = append(current.keys, text)
+		}
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// estimateRows - ответ EXPLAIN ESTIMATE
+func estimateRows(data ...[]any) *fakeRows {
+	return &fakeRows{
+		columns: []fakeColumnType{
+			{name: "database", typ: "String", scan: reflect.TypeOf("")},
+			{name: "table", typ: "String", scan: reflect.TypeOf("")},
+			{name: "parts", typ: "UInt64", scan: reflect.TypeOf(uint64(0))},
+			{name: "rows", typ: "UInt64", scan: reflect.TypeOf(uint64(0))},
+			{name: "marks", typ: "UInt64", scan: reflect.TypeOf(uint64(0))},
+		},
+		data: data,
+	}
+}
+
+// explainRows - ответ EXPLAIN indexes = 1
+func explainRows(lines ...string) *fakeRows {
+	rows := &fakeRows{columns: []fakeColumnType{{name: "explain", typ: "String", scan: reflect.TypeOf("")}}}
+	for _, line := range lines {
+		rows.data = append(rows.data, []any{line})
+	}
+	return rows
+}
+
+// fullScanPlan - план чтения без использования индексов
+var fullScanPlan = []string{
+	"Expression ((Projection + Before ORDER BY))",
+	"  ReadFromMergeTree (default.events)",
+	"  Indexes:",
+	"    MinMax",
+	"      Keys:",
+	"        ts",
+	"      Condition: true",
+	"      Parts: 120/120",
+	"    Partition",
+	"      Keys:",
+	"        toYYYYMM(ts)",
+	"      Condition: true",
+	"      Parts: 120/120",
+	"    PrimaryKey",
+	"      Keys:",
+	"        user_id",
+	"        ts",
+	"      Condition: true",
+	"      Parts: 120/120",
+	"      Granules: 146484/146484",
+}
+
+func TestParseIndexUsage(t *testing.T) {
+	got := parseIndexUsage(fullScanPlan)
+	want := []indexUsage{
+		{kind: "MinMax", keys: []string{"ts"}, condition: "true"},
+		{kind: "Partition", keys: []string{"toYYYYMM(ts)"}, condition: "true"},
+		{kind: "PrimaryKey", keys: []string{"user_id", "ts"}, condition: "true"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseIndexUsage() = %#v, want %#v", got, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		512:       "512B",
+		1536:      "1.5KiB",
+		10 << 30:  "10.0GiB",
+		3 << 40:   "3.0TiB",
+		1<<50 + 1: "1.0PiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestQueryStreamCostLimits(t *testing.T) {
+	query := "SELECT * FROM events"
+
+	t.Run("Запрос отклонён", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{
+			estimateRows([]any{"default", "events", uint64(120), uint64(1_200_000_000), uint64(146484)}),
+			explainRows(fullScanPlan...),
+		}}
+		client := &DefaultClient{conn: conn, costLimits: CostLimits{MaxRows: 100_000_000}}
+
+		_, err := client.QueryData(context.Background(), query, QueryOptions{})
+
+		var costErr *CostLimitError
+		if !errors.As(err, &costErr) {
+			t.Fatalf("QueryData() error = %v, want CostLimitError", err)
+		}
+		if costErr.Estimate.Rows != 1_200_000_000 || costErr.Estimate.Parts != 120 {
+			t.Errorf("Estimate = %+v", costErr.Estimate)
+		}
+		for _, want := range []string{"读取行数1200000000超过上限100000000", "default.events", "按分区键(toYYYYMM(ts))", "主键列(user_id, ts)"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("error = %q, want %q", err, want)
+			}
+		}
+		wantQueries := []string{"EXPLAIN ESTIMATE " + query, "EXPLAIN indexes = 1 " + query}
+		if !reflect.DeepEqual(conn.queries, wantQueries) {
+			t.Errorf("выполнены запросы %q, want %q", conn.queries, wantQueries)
+		}
+	})
+
+	t.Run("Оценка байтов", func(t *testing.T) {
+		total := uint64(1000)
+		totalBytes := uint64(8000)
+		conn := &fakeConn{responses: []*fakeRows{
+			estimateRows([]any{"default", "events", uint64(1), uint64(500), uint64(1)}),
+			{
+				columns: []fakeColumnType{
+					{name: "total_rows", typ: "Nullable(UInt64)", scan: reflect.TypeOf(&total)},
+					{name: "total_bytes", typ: "Nullable(UInt64)", scan: reflect.TypeOf(&totalBytes)},
+				},
+				data: [][]any{{&total, &totalBytes}},
+			},
+			explainRows(),
+		}}
+		client := &DefaultClient{conn: conn, costLimits: CostLimits{MaxBytes: 1000}}
+
+		_, err := client.QueryData(context.Background(), query, QueryOptions{})
+
+		var costErr *CostLimitError
+		if !errors.As(err, &costErr) || costErr.Estimate.Bytes != 4000 {
+			t.Fatalf("QueryData() error = %v, want оценку 4000 байт", err)
+		}
+	})
+
+	t.Run("Запрос в пределах", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{
+			estimateRows([]any{"default", "events", uint64(1), uint64(10), uint64(1)}),
+			{
+				columns: []fakeColumnType{{name: "n", typ: "UInt8", scan: reflect.TypeOf(uint8(0))}},
+				data:    [][]any{{uint8(1)}},
+			},
+		}}
+		client := &DefaultClient{conn: conn, costLimits: CostLimits{MaxRows: 100, MaxParts: 10}}
+
+		result, err := client.QueryData(context.Background(), query, QueryOptions{})
+		if err != nil {
+			t.Fatalf("QueryData() error = %v", err)
+		}
+		if len(result.Rows) != 1 || len(conn.queries) != 2 {
+			t.Errorf("QueryData() rows = %v, запросы %q", result.Rows, conn.queries)
+		}
+	})
+
+	t.Run("Не SELECT не оценивается", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{explainRows("x")}}
+		client := &DefaultClient{conn: conn, costLimits: CostLimits{MaxRows: 1}}
+
+		if _, err := client.QueryData(context.Background(), "SHOW TABLES", QueryOptions{}); err != nil {
+			t.Fatalf("QueryData() error = %v", err)
+		}
+		if len(conn.queries) != 1 {
+			t.Errorf("выполнены запросы %q, want только сам запрос", conn.queries)
+		}
+	})
+}
@@ -0,0 +1,383 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// Protocol 与ClickHouse通信使用的接口
+type Protocol string
+
+const (
+	// ProtocolNative 原生TCP协议，默认端口9000
+	ProtocolNative Protocol = "native"
+	// ProtocolHTTP HTTP(S)接口，默认端口8123，HTTPS为8443
+	ProtocolHTTP Protocol = "http"
+)
+
+// httpCompressionMethods 只有HTTP接口支持的压缩方式，由HTTP的Content-Encoding协商
+var httpCompressionMethods = map[string]clickhouse.CompressionMethod{
+	"gzip":    clickhouse.CompressionGZIP,
+	"deflate": clickhouse.CompressionDeflate,
+	"br":      clickhouse.CompressionBrotli,
+}
+
+// compressionMethod 返回配置的压缩方式。未配置时原生协议使用lz4，HTTP不压缩
+func (cfg Config) compressionMethod() (clickhouse.CompressionMethod, error) {
+	if method, ok := compressionMethods[cfg.Compression]; ok {
+		return method, nil
+	}
+	if method, ok := httpCompressionMethods[cfg.Compression]; ok {
+		if cfg.Protocol != ProtocolHTTP {
+			return 0, fmt.Errorf("压缩方式%s只能用于HTTP接口，原生协议支持: lz4, zstd, none", cfg.Compression)
+		}
+		return method, nil
+	}
+	switch {
+	case cfg.Compression != "":
+		return 0, fmt.Errorf("未知的压缩方式: %q(支持: lz4, zstd, none, HTTP接口还支持gzip, deflate, br)", cfg.Compression)
+	case cfg.Protocol == ProtocolHTTP:
+		return clickhouse.CompressionNone, nil
+	}
+	return clickhouse.CompressionLZ4, nil
+}
+
+// configureHTTP 设置HTTP接口的驱动选项
+func configureHTTP(opts *clickhouse.Options, cfg Config, dialer *net.Dialer) error {
+	opts.Protocol = clickhouse.HTTP
+	opts.HttpHeaders = cfg.HTTPHeaders
+	opts.HttpUrlPath = cfg.HTTPPath
+
+	// 驱动的database/sql接口不使用DialStrategy，只支持按顺序和轮询选择地址
+	if cfg.ConnOpenStrategy == ConnOpenRoundRobin || cfg.ConnOpenStrategy == ConnOpenRandom {
+		opts.ConnOpenStrategy = clickhouse.ConnOpenRoundRobin
+	}
+
+	if cfg.Secure {
+		creds, err := newTLSCredentials(cfg.TLS)
+		if err != nil {
+			return err
+		}
+		// 配置TLS后驱动使用https
+		if opts.TLS, err = creds.transportConfig(cfg.Addr); err != nil {
+			return err
+		}
+	}
+
+	if cfg.HTTPProxy != "" {
+		proxy, err := newProxyDialer(cfg.HTTPProxy, dialer)
+		if err != nil {
+			return err
+		}
+		opts.DialContext = proxy.dialContext
+	}
+	return nil
+}
+
+// openHTTP 通过HTTP接口连接ClickHouse。驱动v2.20只在database/sql接口中支持HTTP，
+// 由httpConn适配为与原生协议相同的driver.Conn
+func openHTTP(opts *clickhouse.Options) driver.Conn {
+	// OpenDB不接受连接池配置，改为在sql.DB上设置
+	maxOpen, maxIdle, lifetime := opts.MaxOpenConns, opts.MaxIdleConns, opts.ConnMaxLifetime
+	opts.MaxOpenConns, opts.MaxIdleConns, opts.ConnMaxLifetime = 0, 0, 0
+
+	db := clickhouse.OpenDB(opts)
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+	return &httpConn{db: db}
+}
+
+// httpConn 基于database/sql的driver.Conn，只实现客户端用到的查询方法。
+// 驱动将HTTP接口的服务端异常作为文本错误返回，这里转换为与原生协议相同的*clickhouse.Exception
+type httpConn struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	version *driver.ServerVersion
+}
+
+// errHTTPUnsupported HTTP接口不支持的驱动方法
+var errHTTPUnsupported = errors.New("HTTP接口不支持该操作")
+
+func (c *httpConn) Contributors() []string { return nil }
+
+// ServerVersion 查询并缓存服务端版本和时区
+func (c *httpConn) ServerVersion() (*driver.ServerVersion, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != nil {
+		return c.version, nil
+	}
+
+	var version, timezone string
+	if err := c.QueryRow(context.Background(), "SELECT version(), timezone()").Scan(&version, &timezone); err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("无法加载服务端时区%s: %w", timezone, err)
+	}
+	c.version = &driver.ServerVersion{
+		Name:     "ClickHouse",
+		Version:  proto.ParseVersion(version),
+		Timezone: location,
+	}
+	return c.version, nil
+}
+
+func (c *httpConn) Select(ctx context.Context, dest any, query string, args ...any) error {
+	return errHTTPUnsupported
+}
+
+// Query 执行查询，列类型与原生协议相同
+func (c *httpConn) Query(ctx context.Context, query string, args ...any) (driver.Rows, error) {
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, httpError(err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		rows.Close()
+		return nil, httpError(err)
+	}
+	types := make([]driver.ColumnType, len(columnTypes))
+	for i, ct := range columnTypes {
+		types[i] = httpColumnType{ct}
+	}
+	return &httpRows{rows: rows, columnTypes: types}, nil
+}
+
+// QueryRow 执行单行查询
+func (c *httpConn) QueryRow(ctx context.Context, query string, args ...any) driver.Row {
+	return httpRow{row: c.db.QueryRowContext(ctx, query, args...)}
+}
+
+func (c *httpConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	return nil, errHTTPUnsupported
+}
+
+// Exec 执行不返回结果的语句
+func (c *httpConn) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := c.db.ExecContext(ctx, query, args...)
+	return httpError(err)
+}
+
+func (c *httpConn) AsyncInsert(ctx context.Context, query string, wait bool, args ...any) error {
+	return errHTTPUnsupported
+}
+
+// Ping 检查连接
+func (c *httpConn) Ping(ctx context.Context) error {
+	return httpError(c.db.PingContext(ctx))
+}
+
+// Stats 返回连接池状态
+func (c *httpConn) Stats() driver.Stats {
+	stats := c.db.Stats()
+	return driver.Stats{
+		MaxOpenConns: stats.MaxOpenConnections,
+		Open:         stats.OpenConnections,
+		Idle:         stats.Idle,
+	}
+}
+
+// Close 关闭连接池
+func (c *httpConn) Close() error {
+	return c.db.Close()
+}
+
+// httpRows 将sql.Rows适配为driver.Rows
+type httpRows struct {
+	rows        *sql.Rows
+	columnTypes []driver.ColumnType
+}
+
+func (r *httpRows) Next() bool { return r.rows.Next() }
+
+func (r *httpRows) Scan(dest ...any) error { return httpError(r.rows.Scan(dest...)) }
+
+func (r *httpRows) ScanStruct(dest any) error { return errHTTPUnsupported }
+
+func (r *httpRows) ColumnTypes() []driver.ColumnType { return r.columnTypes }
+
+func (r *httpRows) Totals(dest ...any) error { return errHTTPUnsupported }
+
+func (r *httpRows) Columns() []string {
+	columns := make([]string, len(r.columnTypes))
+	for i, ct := range r.columnTypes {
+		columns[i] = ct.Name()
+	}
+	return columns
+}
+
+func (r *httpRows) Close() error { return r.rows.Close() }
+
+func (r *httpRows) Err() error { return httpError(r.rows.Err()) }
+
+// httpRow 将sql.Row适配为driver.Row
+type httpRow struct {
+	row *sql.Row
+}
+
+func (r httpRow) Err() error { return httpError(r.row.Err()) }
+
+func (r httpRow) Scan(dest ...any) error { return httpError(r.row.Scan(dest...)) }
+
+func (r httpRow) ScanStruct(dest any) error { return errHTTPUnsupported }
+
+// httpColumnType 将sql.ColumnType适配为driver.ColumnType
+type httpColumnType struct {
+	*sql.ColumnType
+}
+
+func (ct httpColumnType) Nullable() bool {
+	nullable, _ := ct.ColumnType.Nullable()
+	return nullable
+}
+
+// httpException 驱动返回的HTTP错误中的服务端异常，
+// 如"clickhouse [execute]:: 404 code: Code: 60. DB::Exception: ... (UNKNOWN_TABLE) (version 24.3.1.1)"
+var httpException = regexp.MustCompile(`(?s)^clickhouse \[execute\]:: \d+ code: Code: (\d+)\. DB::Exception: (.*?)(?: \(version [^)]*\))?\s*$`)
+
+// httpError 将HTTP错误中的服务端异常转换为*clickhouse.Exception，其他错误原样返回
+func httpError(err error) error {
+	if err == nil {
+		return nil
+	}
+	m := httpException.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	code, convErr := strconv.ParseInt(m[1], 10, 32)
+	if convErr != nil {
+		return err
+	}
+	return &clickhouse.Exception{Code: int32(code), Name: "DB::Exception", Message: m[2]}
+}
+
+// proxyDialer 通过HTTP代理的CONNECT方法建立到ClickHouse的隧道，
+// TLS握手由HTTP传输层在隧道上完成
+type proxyDialer struct {
+	proxy  *url.URL
+	dialer *net.Dialer
+}
+
+// newProxyDialer 解析代理URL，支持http和https代理，URL中可以包含用户名和密码
+func newProxyDialer(proxyURL string, dialer *net.Dialer) (*proxyDialer, error) {
+	proxy, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的代理URL: %w", err)
+	}
+	if proxy.Scheme != "http" && proxy.Scheme != "https" {
+		return nil, fmt.Errorf("无效的代理URL %s: 只支持http和https代理", proxy.Redacted())
+	}
+	if proxy.Hostname() == "" {
+		return nil, fmt.Errorf("无效的代理URL %s: 缺少主机名", proxy.Redacted())
+	}
+	return &proxyDialer{proxy: proxy, dialer: dialer}, nil
+}
+
+// address 返回代理的host:port，未指定端口时使用协议的默认端口
+func (d *proxyDialer) address() string {
+	if port := d.proxy.Port(); port != "" {
+		return net.JoinHostPort(d.proxy.Hostname(), port)
+	}
+	if d.proxy.Scheme == "https" {
+		return net.JoinHostPort(d.proxy.Hostname(), "443")
+	}
+	return net.JoinHostPort(d.proxy.Hostname(), "80")
+}
+
+// dialContext 连接代理并请求到addr的隧道
+func (d *proxyDialer) dialContext(ctx context.Context, addr string) (net.Conn, error) {
+	if d.dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.dialer.Timeout)
+		defer cancel()
+	}
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.address())
+	if err != nil {
+		return nil, err
+	}
+	if d.proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxy.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("连接代理%s失败: %w", d.proxy.Redacted(), err)
+		}
+		conn = tlsConn
+	}
+
+	if err := d.connect(ctx, conn, addr); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// connect 发送CONNECT请求并检查代理的响应
+func (d *proxyDialer) connect(ctx context.Context, conn net.Conn, addr string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user := d.proxy.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return fmt.Errorf("向代理%s发送CONNECT请求失败: %w", d.proxy.Redacted(), err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return fmt.Errorf("读取代理%s的响应失败: %w", d.proxy.Redacted(), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("代理%s拒绝连接%s: %s", d.proxy.Redacted(), addr, resp.Status)
+	}
+	// 隧道建立前服务端不会发送数据，缓冲区中不应有剩余内容
+	if reader.Buffered() > 0 {
+		return fmt.Errorf("代理%s在CONNECT响应后发送了多余的数据", d.proxy.Redacted())
+	}
+	return nil
+}
+
+// ParseHTTPHeader 解析"Name: value"格式的HTTP头，返回规范化的名称和值
+func ParseHTTPHeader(header string) (string, string, error) {
+	name, value, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return "", "", fmt.Errorf("无效的HTTP头%q，格式应为\"Name: value\"", header)
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
@@ -0,0 +1,295 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestCompressionMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		want    clickhouse.CompressionMethod
+		wantErr bool
+	}{
+		{name: "Родной протокол по умолчанию", config: Config{}, want: clickhouse.CompressionLZ4},
+		{name: "HTTP по умолчанию без сжатия", config: Config{Protocol: ProtocolHTTP}, want: clickhouse.CompressionNone},
+		{name: "zstd", config: Config{Compression: "zstd"}, want: clickhouse.CompressionZSTD},
+		{name: "lz4 по HTTP", config: Config{Protocol: ProtocolHTTP, Compression: "lz4"}, want: clickhouse.CompressionLZ4},
+		{name: "gzip по HTTP", config: Config{Protocol: ProtocolHTTP, Compression: "gzip"}, want: clickhouse.CompressionGZIP},
+		{name: "br по HTTP", config: Config{Protocol: ProtocolHTTP, Compression: "br"}, want: clickhouse.CompressionBrotli},
+		{name: "gzip по родному протоколу", config: Config{Compression: "gzip"}, wantErr: true},
+		{name: "Неизвестное сжатие", config: Config{Protocol: ProtocolHTTP, Compression: "snappy"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.config.compressionMethod()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compressionMethod() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("compressionMethod() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    int32
+		wantMessage string
+	}{
+		{
+			name:        "Исключение сервера",
+			err:         errors.New("clickhouse [execute]:: 404 code: Code: 60. DB::Exception: Table default.t does not exist. (UNKNOWN_TABLE) (version 24.3.1.1)\n"),
+			wantCode:    60,
+			wantMessage: "Table default.t does not exist. (UNKNOWN_TABLE)",
+		},
+		{
+			name:        "Многострочное сообщение без версии",
+			err:         errors.New("clickhouse [execute]:: 400 code: Code: 62. DB::Exception: Syntax error: failed at position 8:\n\nSELEC 1\n"),
+			wantCode:    62,
+			wantMessage: "Syntax error: failed at position 8:\n\nSELEC 1",
+		},
+		{name: "Сетевая ошибка", err: errors.New("dial tcp 127.0.0.1:8123: connect: connection refused")},
+		{name: "Ответ прокси без исключения", err: errors.New("clickhouse [execute]:: 502 code: Bad Gateway")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := httpError(tt.err)
+			var exception *clickhouse.Exception
+			if !errors.As(err, &exception) {
+				if tt.wantCode != 0 {
+					t.Fatalf("httpError() = %v, ожидалось исключение с кодом %d", err, tt.wantCode)
+				}
+				if err != tt.err {
+					t.Errorf("httpError() = %v, ожидалась исходная ошибка", err)
+				}
+				return
+			}
+			if exception.Code != tt.wantCode || exception.Message != tt.wantMessage {
+				t.Errorf("httpError() = %d %q, ожидалось %d %q", exception.Code, exception.Message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+
+	if httpError(nil) != nil {
+		t.Error("httpError(nil) != nil")
+	}
+}
+
+func TestParseHTTPHeader(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{header: "X-Request-Source: mcp", wantName: "X-Request-Source", wantValue: "mcp"},
+		{header: "authorization:Bearer token", wantName: "Authorization", wantValue: "Bearer token"},
+		{header: "X-Empty:", wantName: "X-Empty"},
+		{header: "X-No-Colon", wantErr: true},
+		{header: ": value", wantErr: true},
+		{header: "X Bad: value", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		name, value, err := ParseHTTPHeader(tt.header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHTTPHeader(%q) error = %v, wantErr = %v", tt.header, err, tt.wantErr)
+			continue
+		}
+		if name != tt.wantName || value != tt.wantValue {
+			t.Errorf("ParseHTTPHeader(%q) = %q, %q, ожидалось %q, %q", tt.header, name, value, tt.wantName, tt.wantValue)
+		}
+	}
+}
+
+// startProxy 启动只支持CONNECT的HTTP代理，要求Basic认证user:pass，返回地址和收到的CONNECT目标
+func startProxy(t *testing.T) (string, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	targets := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil || req.Method != http.MethodConnect {
+					return
+				}
+				targets <- req.Host
+				if user, pass, ok := parseProxyAuth(req.Header.Get("Proxy-Authorization")); !ok || user != "user" || pass != "pass" {
+					io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+					return
+				}
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					return
+				}
+				defer upstream.Close()
+				io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return listener.Addr().String(), targets
+}
+
+// parseProxyAuth 解析Proxy-Authorization头中的Basic认证
+func parseProxyAuth(header string) (string, string, bool) {
+	req := &http.Request{Header: http.Header{"Authorization": {header}}}
+	return req.BasicAuth()
+}
+
+func TestProxyDialer(t *testing.T) {
+	var requests = make(chan *http.Request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		fmt.Fprint(w, "Ok.")
+	}))
+	t.Cleanup(server.Close)
+	serverAddr := strings.TrimPrefix(server.URL, "http://")
+	proxyAddr, targets := startProxy(t)
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	t.Run("Туннель через прокси", func(t *testing.T) {
+		proxy, err := newProxyDialer("http://user:pass@"+proxyAddr, dialer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return proxy.dialContext(ctx, addr)
+			},
+		}}
+		resp, err := client.Get(server.URL + "/ping")
+		if err != nil {
+			t.Fatalf("запрос через прокси: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "Ok." {
+			t.Errorf("ответ %q, ожидалось %q", body, "Ok.")
+		}
+		if target := <-targets; target != serverAddr {
+			t.Errorf("CONNECT %s, ожидалось %s", target, serverAddr)
+		}
+		if r := <-requests; r.URL.Path != "/ping" {
+			t.Errorf("путь запроса %s, ожидалось /ping", r.URL.Path)
+		}
+	})
+
+	t.Run("Прокси отклоняет подключение", func(t *testing.T) {
+		proxy, err := newProxyDialer("http://user:wrong@"+proxyAddr, dialer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = proxy.dialContext(context.Background(), serverAddr)
+		if err == nil || !strings.Contains(err.Error(), "407") {
+			t.Errorf("dialContext() error = %v, ожидался статус 407", err)
+		}
+		if strings.Contains(fmt.Sprint(err), "wrong") {
+			t.Errorf("ошибка содержит пароль прокси: %v", err)
+		}
+		<-targets
+	})
+
+	t.Run("Некорректный URL прокси", func(t *testing.T) {
+		for _, proxyURL := range []string{"socks5://proxy:1080", "http://", "://proxy"} {
+			if _, err := newProxyDialer(proxyURL, dialer); err == nil {
+				t.Errorf("newProxyDialer(%q) без ошибки", proxyURL)
+			}
+		}
+	})
+}
+
+func TestNewClientHTTP(t *testing.T) {
+	// 服务端对所有请求返回认证失败，用于检查请求内容和错误转换
+	requests := make(chan *http.Request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- r:
+		default:
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, "Code: 516. DB::Exception: default: Authentication failed. (AUTHENTICATION_FAILED) (version 24.3.1.1)\n")
+	}))
+	t.Cleanup(server.Close)
+	proxyAddr, targets := startProxy(t)
+
+	_, err := NewClient(context.Background(), Config{
+		Protocol:    ProtocolHTTP,
+		Addr:        []string{strings.TrimPrefix(server.URL, "http://")},
+		Database:    "logs",
+		Username:    "reader",
+		Password:    "secret",
+		Compression: "gzip",
+		HTTPHeaders: map[string]string{"X-Request-Source": "mcp"},
+		HTTPPath:    "/clickhouse",
+		HTTPProxy:   "http://user:pass@" + proxyAddr,
+		ReadOnly:    true,
+	})
+
+	var exception *clickhouse.Exception
+	if !errors.As(err, &exception) || exception.Code != 516 {
+		t.Fatalf("NewClient() error = %v, ожидалось исключение с кодом 516", err)
+	}
+
+	<-targets
+	r := <-requests
+	if r.URL.Path != "/clickhouse" {
+		t.Errorf("путь %s, ожидалось /clickhouse", r.URL.Path)
+	}
+	if got := r.Header.Get("X-Request-Source"); got != "mcp" {
+		t.Errorf("X-Request-Source = %q, ожидалось mcp", got)
+	}
+	if user, pass, ok := r.BasicAuth(); !ok || user != "reader" || pass != "secret" {
+		t.Errorf("авторизация %q:%q, ожидалось reader:secret", user, pass)
+	}
+	query := r.URL.Query()
+	for name, want := range map[string]string{"database": "logs", "readonly": "1", "enable_http_compression": "1"} {
+		if got := query.Get(name); got != want {
+			t.Errorf("параметр %s = %q, ожидалось %q", name, got, want)
+		}
+	}
+}
+
+func TestNewClientHTTPOptionsRequireHTTP(t *testing.T) {
+	for _, cfg := range []Config{
+		{Addr: []string{"127.0.0.1:9000"}, HTTPPath: "/clickhouse"},
+		{Addr: []string{"127.0.0.1:9000"}, HTTPProxy: "http://proxy:3128"},
+		{Protocol: "grpc", Addr: []string{"127.0.0.1:9100"}},
+		{Addr: []string{"127.0.0.1:9000"}, Compression: "gzip"},
+	} {
+		if _, err := NewClient(context.Background(), cfg); err == nil {
+			t.Errorf("NewClient(%+v) без ошибки", cfg)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package clickhouse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidIdentifier 数据库名或表名不合法
+var ErrInvalidIdentifier = errors.New("无效的标识符")
+
+// maxIdentifierLength 标识符最大字节数，与常见文件系统的文件名长度限制一致
+const maxIdentifierLength = 255
+
+// ValidateIdentifier 检查数据库名或表名是否合法。
+// 允许点、连字符和Unicode字符，拒绝空名称、非法UTF-8、控制字符和超长名称
+func ValidateIdentifier(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: 名称为空", ErrInvalidIdentifier)
+	case len(name) > maxIdentifierLength:
+		return fmt.Errorf("%w: 名称超过%d字节", ErrInvalidIdentifier, maxIdentifierLength)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("%w: 名称不是合法的UTF-8", ErrInvalidIdentifier)
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: 名称包含控制字符 %U", ErrInvalidIdentifier, r)
+		}
+	}
+	return nil
+}
+
+// QuoteIdentifier 用反引号包围标识符，转义其中的反斜杠和反引号
+func QuoteIdentifier(name string) string {
+	var b strings.Builder
+	b.Grow(len(name) + 2)
+	b.WriteByte('`')
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\\', '`':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('`')
+	return b.String()
+}
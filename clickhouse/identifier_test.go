@@ -0,0 +1,59 @@
+package clickhouse
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateIdentifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "Обычное имя", input: "events"},
+		{name: "Имя с точкой и дефисом", input: "my-db.v2"},
+		{name: "Юникод", input: "данные_2024"},
+		{name: "Обратная кавычка", input: "a`b"},
+		{name: "Пустое имя", input: "", wantErr: true},
+		{name: "Нулевой байт", input: "a\x00b", wantErr: true},
+		{name: "Перевод строки", input: "a\nb", wantErr: true},
+		{name: "Некорректный UTF-8", input: "\xff", wantErr: true},
+		{name: "Слишком длинное имя", input: strings.Repeat("a", 256), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIdentifier(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateIdentifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidIdentifier) {
+				t.Errorf("ValidateIdentifier() error = %v, want ErrInvalidIdentifier", err)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Обычное имя", input: "events", want: "`events`"},
+		{name: "Имя с точкой", input: "a.b", want: "`a.b`"},
+		{name: "Обратная кавычка", input: "a`b", want: "`a\\`b`"},
+		{name: "Обратный слеш", input: `a\b`, want: "`a\\\\b`"},
+		{name: "Попытка инъекции", input: "t` ; DROP TABLE x; --", want: "`t\\` ; DROP TABLE x; --`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuoteIdentifier(tt.input); got != tt.want {
+				t.Errorf("QuoteIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"clickhouse-mcp/lexer"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// applyRowLimit 为查询应用行数限制，多取一行用于判断结果是否被截断。
+// SELECT语句改写为 SELECT * FROM (<query>) LIMIT n+1，末尾的SETTINGS子句移到外层，FORMAT子句丢弃；
+// SHOW/DESCRIBE/EXPLAIN等无法作为子查询的语句，以及改写会改变结果的SELECT
+// (WITH TOTALS、extremes或输出列重名)通过max_result_rows和result_overflow_mode=break限制
+func applyRowLimit(query string, limit int) (string, clickhouse.Settings) {
+	if limit <= 0 {
+		return query, nil
+	}
+
+	statements := splitStatements(query)
+	if len(statements) != 1 || !isWrappable(statements[0]) || !keepsResultShape(statements[0].tokens) {
+		return query, clickhouse.Settings{
+			"max_result_rows":      limit + 1,
+			"result_overflow_mode": "break",
+		}
+	}
+
+	body, settings := splitTrailingClauses(statements[0].text)
+	wrapped := fmt.Sprintf("SELECT * FROM (\n%s\n) LIMIT %d", body, limit+1)
+	if settings != "" {
+		wrapped += " " + settings
+	}
+	return wrapped, nil
+}
+
+// resultBytesSettings 为HTTP接口添加结果大小的上限。HTTP驱动读完整个响应才返回第一行，
+// 调用方读取时停止已无法减少传输量，因此由服务端在结果达到max_result_bytes后停止
+// (result_overflow_mode=break)，超出上限的数据块仍会返回
+func resultBytesSettings(settings clickhouse.Settings, maxBytes int) clickhouse.Settings {
+	limited := clickhouse.Settings{
+		"max_result_bytes":     maxBytes,
+		"result_overflow_mode": "break",
+	}
+	for name, value := range settings {
+		limited[name] = value
+	}
+	return limited
+}
+
+// approxBlockBytes 估算解码后的值在服务端数据块中的大小，与max_result_bytes的计算方式相近:
+// 数值按8字节，字符串、数组和Map另加8字节的偏移，每个值再加1字节的NULL标记
+func approxBlockBytes(v any) int {
+	switch v := v.(type) {
+	case string:
+		return len(v) + 9
+	case []any:
+		size := 9
+		for _, item := range v {
+			size += approxBlockBytes(item)
+		}
+		return size
+	case map[string]any:
+		size := 9
+		for key, item := range v {
+			size += len(key) + 9 + approxBlockBytes(item)
+		}
+		return size
+	}
+	return 9
+}
+
+// isWrappable 判断语句能否作为子查询: SELECT、WITH ... SELECT 或括号包围的SELECT
+func isWrappable(stmt sqlStatement) bool {
+	for _, tok := range stmt.tokens {
+		if tok.IsPunct("(") {
+			continue
+		}
+		return tok.IsKeyword("SELECT") || tok.IsKeyword("WITH")
+	}
+	return false
+}
+
+// keepsResultShape 判断改写为子查询后结果是否不变。外层SELECT会丢弃WITH TOTALS和extremes
+// 产生的额外行，子查询中输出列重名时服务端直接报错
+func keepsResultShape(tokens []lexer.Token) bool {
+	for i, tok := range tokens {
+		if tok.IsKeyword("TOTALS") && i > 0 && tokens[i-1].IsKeyword("WITH") {
+			return false
+		}
+		if tok.IsKeyword("extremes") && i+1 < len(tokens) && tokens[i+1].IsPunct("=") {
+			return false
+		}
+	}
+	return !hasDuplicateColumns(tokens)
+}
+
+// hasDuplicateColumns 判断第一个顶层SELECT的输出列是否可能重名: 相同的别名、列名或表达式，
+// 以及*、COLUMNS与其他列同时出现。限定名t.a按a计算，无法确定时视为重名
+func hasDuplicateColumns(tokens []lexer.Token) bool {
+	base := 0
+	for base < len(tokens) && tokens[base].IsPunct("(") {
+		base++
+	}
+
+	var (
+		depth = base
+		start = -1
+	)
+	for i := base; i < len(tokens) && start == -1; i++ {
+		switch {
+		case isOpenBracket(tokens[i]):
+			depth++
+		case isCloseBracket(tokens[i]):
+			depth--
+		case depth == base && tokens[i].IsKeyword("SELECT"):
+			start = i + 1
+		}
+	}
+	if start == -1 {
+		return false
+	}
+	start = skipSelectModifiers(tokens, start)
+
+	var (
+		items [][]lexer.Token
+		item  []lexer.Token
+	)
+	depth = base
+scan:
+	for i := start; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case isOpenBracket(tok):
+			depth++
+		case isCloseBracket(tok):
+			depth--
+			if depth < base {
+				break scan
+			}
+		case depth > base:
+		case tok.IsPunct(","):
+			items = append(items, item)
+			item = nil
+			continue
+		case isSelectListEnd(tokens, i):
+			break scan
+		}
+		item = append(item, tok)
+	}
+	items = append(items, item)
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		name := columnName(item)
+		if name == "*" && len(items) > 1 || seen[name] {
+			return true
+		}
+		seen[name] = true
+	}
+	return false
+}
+
+// skipSelectModifiers 跳过SELECT之后的DISTINCT [ON (...)]、ALL和TOP n [WITH TIES]
+func skipSelectModifiers(tokens []lexer.Token, i int) int {
+	if i < len(tokens) && (tokens[i].IsKeyword("DISTINCT") || tokens[i].IsKeyword("ALL")) {
+		i++
+		if i+1 < len(tokens) && tokens[i].IsKeyword("ON") && tokens[i+1].IsPunct("(") {
+			depth := 0
+			for i++; i < len(tokens); i++ {
+				if isOpenBracket(tokens[i]) {
+					depth++
+				} else if isCloseBracket(tokens[i]) {
+					depth--
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+		}
+	}
+	if i+1 < len(tokens) && tokens[i].IsKeyword("TOP") && tokens[i+1].Type == lexer.Number {
+		i += 2
+		if i+1 < len(tokens) && tokens[i].IsKeyword("WITH") && tokens[i+1].IsKeyword("TIES") {
+			i += 2
+		}
+	}
+	return i
+}
+
+// isSelectListEnd 判断位置i是否为SELECT列表之后的子句
+func isSelectListEnd(tokens []lexer.Token, i int) bool {
+	for _, keyword := range []string{
+		"FROM", "PREWHERE", "WHERE", "GROUP", "HAVING", "WINDOW", "QUALIFY", "ORDER",
+		"LIMIT", "OFFSET", "UNION", "EXCEPT", "INTERSECT", "INTO",
+	} {
+		if tokens[i].IsKeyword(keyword) {
+			return true
+		}
+	}
+	return isSettingsClause(tokens, i) || isFormatClause(tokens, i)
+}
+
+// columnName 返回SELECT列表中一项的输出列名: 别名、限定名的最后一段或表达式文本，
+// *、t.*和COLUMNS(...)(包括EXCEPT等修饰)返回"*"
+func columnName(item []lexer.Token) string {
+	n := len(item)
+	switch {
+	case n == 0:
+		return ""
+	case n >= 2 && item[n-2].IsKeyword("AS"):
+		return unquote(item[n-1])
+	case isStar(item[0]) || n >= 3 && item[1].IsPunct(".") && isStar(item[2]):
+		return "*"
+	case isQualifiedName(item):
+		return unquote(item[n-1])
+	}
+
+	var b strings.Builder
+	for _, tok := range item {
+		b.WriteString(tok.Text)
+	}
+	return b.String()
+}
+
+// isStar 判断是否为*或COLUMNS匹配器
+func isStar(tok lexer.Token) bool {
+	return tok.IsPunct("*") || tok.IsKeyword("COLUMNS")
+}
+
+// isQualifiedName 判断词法单元是否构成 name 或 db.table.name 形式的标识符
+func isQualifiedName(tokens []lexer.Token) bool {
+	if len(tokens)%2 == 0 {
+		return false
+	}
+	for i, tok := range tokens {
+		if i%2 == 1 {
+			if !tok.IsPunct(".") {
+				return false
+			}
+		} else if tok.Type != lexer.Word && tok.Type != lexer.QuotedIdentifier {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTrailingClauses 拆分语句末尾顶层的SETTINGS和FORMAT子句。
+// 返回不含这些子句的查询主体，以及可放到外层查询的SETTINGS子句
+func splitTrailingClauses(query string) (string, string) {
+	tokens := lexer.Significant(lexer.Tokenize(query))
+
+	var (
+		depth       int
+		clauseStart = -1
+	)
+	for i, tok := range tokens {
+		switch {
+		case isOpenBracket(tok):
+			depth++
+		case isCloseBracket(tok):
+			if depth > 0 {
+				depth--
+			}
+		case depth > 0:
+		case tok.IsKeyword("UNION") || tok.IsKeyword("EXCEPT") || tok.IsKeyword("INTERSECT"):
+			// 集合运算之前的SETTINGS/FORMAT属于前一个SELECT
+			clauseStart = -1
+		case clauseStart == -1 && (isSettingsClause(tokens, i) || isFormatClause(tokens, i)):
+			clauseStart = i
+		}
+	}
+	if clauseStart == -1 {
+		return query, ""
+	}
+
+	body := strings.TrimSpace(query[:tokens[clauseStart].Pos])
+
+	var settings string
+	for i := clauseStart; i < len(tokens); i++ {
+		if !isSettingsClause(tokens, i) {
+			continue
+		}
+		end := len(query)
+		for j := i + 1; j < len(tokens); j++ {
+			if isFormatClause(tokens, j) {
+				end = tokens[j].Pos
+				break
+			}
+		}
+		settings = strings.TrimSpace(query[tokens[i].Pos:end])
+		break
+	}
+
+	return body, settings
+}
+
+// isSettingsClause 判断位置i是否为 SETTINGS name = value 子句的开始
+func isSettingsClause(tokens []lexer.Token, i int) bool {
+	return tokens[i].IsKeyword("SETTINGS") &&
+		i+2 < len(tokens) &&
+		(tokens[i+1].Type == lexer.Word || tokens[i+1].Type == lexer.QuotedIdentifier) &&
+		tokens[i+2].IsPunct("=")
+}
+
+// isFormatClause 判断位置i是否为 FORMAT name 子句的开始，FORMAT之后只能是结尾或SETTINGS子句
+func isFormatClause(tokens []lexer.Token, i int) bool {
+	if !tokens[i].IsKeyword("FORMAT") || i+1 >= len(tokens) || tokens[i+1].Type != lexer.Word {
+		return false
+	}
+	return i+2 == len(tokens) || isSettingsClause(tokens, i+2)
+}
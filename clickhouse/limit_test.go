@@ -0,0 +1,196 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestApplyRowLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        string
+		limit        int
+		want         string
+		wantSettings bool
+	}{
+		{
+			name:  "Без лимита",
+			query: "SELECT 1",
+			limit: 0,
+			want:  "SELECT 1",
+		},
+		{
+			name:  "Простой SELECT",
+			query: "SELECT * FROM t",
+			limit: 10,
+			want:  "SELECT * FROM (\nSELECT * FROM t\n) LIMIT 11",
+		},
+		{
+			name:  "Собственный LIMIT сохраняется внутри",
+			query: "SELECT * FROM t LIMIT 1000",
+			limit: 10,
+			want:  "SELECT * FROM (\nSELECT * FROM t LIMIT 1000\n) LIMIT 11",
+		},
+		{
+			name:  "LIMIT BY",
+			query: "SELECT * FROM t LIMIT 1 BY id",
+			limit: 10,
+			want:  "SELECT * FROM (\nSELECT * FROM t LIMIT 1 BY id\n) LIMIT 11",
+		},
+		{
+			name:  "FORMAT отбрасывается",
+			query: "SELECT * FROM t FORMAT JSONEachRow",
+			limit: 10,
+			want:  "SELECT * FROM (\nSELECT * FROM t\n) LIMIT 11",
+		},
+		{
+			name:  "SETTINGS переносится наружу",
+			query: "SELECT * FROM t SETTINGS max_threads = 1, max_block_size = 10 FORMAT TSV",
+			limit: 10,
+			want:  "SELECT * FROM (\nSELECT * FROM t\n) LIMIT 11 SETTINGS max_threads = 1, max_block_size = 10",
+		},
+		{
+			name:  "SETTINGS в подзапросе не трогается",
+			query: "SELECT * FROM (SELECT 1 SETTINGS max_threads = 1)",
+			limit: 5,
+			want:  "SELECT * FROM (\nSELECT * FROM (SELECT 1 SETTINGS max_threads = 1)\n) LIMIT 6",
+		},
+		{
+			name:  "UNION ALL",
+			query: "SELECT 1 UNION ALL SELECT 2",
+			limit: 5,
+			want:  "SELECT * FROM (\nSELECT 1 UNION ALL SELECT 2\n) LIMIT 6",
+		},
+		{
+			name:         "WITH TOTALS через настройки",
+			query:        "SELECT k, count() FROM t GROUP BY k WITH TOTALS",
+			limit:        5,
+			want:         "SELECT k, count() FROM t GROUP BY k WITH TOTALS",
+			wantSettings: true,
+		},
+		{
+			name:         "extremes через настройки",
+			query:        "SELECT k FROM t SETTINGS extremes = 1",
+			limit:        5,
+			want:         "SELECT k FROM t SETTINGS extremes = 1",
+			wantSettings: true,
+		},
+		{
+			name:         "Повторяющиеся столбцы",
+			query:        "SELECT a, a FROM t",
+			limit:        5,
+			want:         "SELECT a, a FROM t",
+			wantSettings: true,
+		},
+		{
+			name:         "Повторяющиеся псевдонимы",
+			query:        "SELECT DISTINCT a + 1 AS x, b AS `x` FROM t",
+			limit:        5,
+			want:         "SELECT DISTINCT a + 1 AS x, b AS `x` FROM t",
+			wantSettings: true,
+		},
+		{
+			name:         "Одинаковые имена из разных таблиц",
+			query:        "WITH 1 AS n SELECT l.id, r.id FROM l JOIN r USING (k)",
+			limit:        5,
+			want:         "WITH 1 AS n SELECT l.id, r.id FROM l JOIN r USING (k)",
+			wantSettings: true,
+		},
+		{
+			name:         "Звёздочка вместе со столбцом",
+			query:        "SELECT *, a FROM t",
+			limit:        5,
+			want:         "SELECT *, a FROM t",
+			wantSettings: true,
+		},
+		{
+			name:  "Разные столбцы",
+			query: "SELECT f(a, a), a, t.b FROM t",
+			limit: 5,
+			want:  "SELECT * FROM (\nSELECT f(a, a), a, t.b FROM t\n) LIMIT 6",
+		},
+		{
+			name:  "Повтор только в подзапросе",
+			query: "WITH x AS (SELECT 1, 1) SELECT a, b FROM (SELECT a, a AS b FROM t)",
+			limit: 5,
+			want:  "SELECT * FROM (\nWITH x AS (SELECT 1, 1) SELECT a, b FROM (SELECT a, a AS b FROM t)\n) LIMIT 6",
+		},
+		{
+			name:  "Комментарий в конце",
+			query: "SELECT 1 -- комментарий",
+			limit: 5,
+			want:  "SELECT * FROM (\nSELECT 1\n) LIMIT 6",
+		},
+		{
+			name:  "Столбец с именем format",
+			query: "SELECT format FROM t",
+			limit: 5,
+			want:  "SELECT * FROM (\nSELECT format FROM t\n) LIMIT 6",
+		},
+		{
+			name:  "CTE",
+			query: "WITH x AS (SELECT 1) SELECT * FROM x",
+			limit: 5,
+			want:  "SELECT * FROM (\nWITH x AS (SELECT 1) SELECT * FROM x\n) LIMIT 6",
+		},
+		{
+			name:         "SHOW через настройки",
+			query:        "SHOW TABLES",
+			limit:        5,
+			want:         "SHOW TABLES",
+			wantSettings: true,
+		},
+		{
+			name:         "EXPLAIN через настройки",
+			query:        "EXPLAIN SELECT 1",
+			limit:        5,
+			want:         "EXPLAIN SELECT 1",
+			wantSettings: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, settings := applyRowLimit(tt.query, tt.limit)
+			if got != tt.want {
+				t.Errorf("applyRowLimit() = %q, want %q", got, tt.want)
+			}
+			if (len(settings) > 0) != tt.wantSettings {
+				t.Fatalf("applyRowLimit() settings = %v, wantSettings %v", settings, tt.wantSettings)
+			}
+			if tt.wantSettings {
+				if settings["max_result_rows"] != tt.limit+1 || settings["result_overflow_mode"] != "break" {
+					t.Errorf("applyRowLimit() settings = %v", settings)
+				}
+			}
+		})
+	}
+}
+
+func TestResultBytesSettings(t *testing.T) {
+	got := resultBytesSettings(clickhouse.Settings{"max_result_rows": 11, "result_overflow_mode": "break"}, 1024)
+	want := clickhouse.Settings{"max_result_rows": 11, "max_result_bytes": 1024, "result_overflow_mode": "break"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resultBytesSettings() = %v, want %v", got, want)
+	}
+}
+
+func TestApproxBlockBytes(t *testing.T) {
+	tests := []struct {
+		value any
+		want  int
+	}{
+		{value: float64(1), want: 9},
+		{value: nil, want: 9},
+		{value: "abc", want: 12},
+		{value: []any{"a", float64(1)}, want: 9 + 10 + 9},
+		{value: map[string]any{"k": "v"}, want: 9 + 10 + 10},
+	}
+	for _, tt := range tests {
+		if got := approxBlockBytes(tt.value); got != tt.want {
+			t.Errorf("approxBlockBytes(%v) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
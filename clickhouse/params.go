@@ -0,0 +1,324 @@
+package clickhouse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"clickhouse-mcp/lexer"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/shopspring/decimal"
+)
+
+// placeholder 查询中的参数占位符{name:Type}
+type placeholder struct {
+	name string
+	typ  *Type
+}
+
+// findPlaceholders 查找查询中的参数占位符，跳过字符串和注释。
+// 同名占位符的类型必须一致
+func findPlaceholders(query string) ([]placeholder, error) {
+	tokens := lexer.Significant(lexer.Tokenize(query))
+
+	var (
+		result []placeholder
+		types  = map[string]string{}
+	)
+	for i := 0; i+2 < len(tokens); i++ {
+		// {'a': 1} 是Map字面量，占位符以名称开头
+		if !tokens[i].IsPunct("{") || tokens[i+1].Type != lexer.Word || !tokens[i+2].IsPunct(":") {
+			continue
+		}
+
+		name := tokens[i+1].Text
+		end := i + 3
+		for end < len(tokens) && !tokens[end].IsPunct("}") {
+			end++
+		}
+		if end == i+3 || end == len(tokens) {
+			return nil, fmt.Errorf("参数占位符{%s:...}不完整，格式应为{名称:类型}", name)
+		}
+
+		typeName := strings.TrimSpace(query[tokens[i+3].Pos:tokens[end-1].End()])
+		typ, err := ParseType(typeName)
+		if err != nil {
+			return nil, fmt.Errorf("参数%s的类型%q无效: %w", name, typeName, err)
+		}
+		if prev, ok := types[name]; ok {
+			if prev != typ.String() {
+				return nil, fmt.Errorf("参数%s的类型不一致: %s和%s", name, prev, typ)
+			}
+		} else {
+			types[name] = typ.String()
+			result = append(result, placeholder{name: name, typ: typ})
+		}
+		i = end
+	}
+	return result, nil
+}
+
+// bindParams 按占位符类型将参数值转换为ClickHouse的文本表示。
+// 每个占位符都必须有对应的参数，每个参数都必须被使用
+func bindParams(query string, params map[string]any) (clickhouse.Parameters, error) {
+	placeholders, err := findPlaceholders(query)
+	if err != nil {
+		return nil, err
+	}
+
+	bound := make(clickhouse.Parameters, len(placeholders))
+	for _, p := range placeholders {
+		value, ok := params[p.name]
+		if !ok {
+			return nil, fmt.Errorf("缺少参数%s(类型%s)", p.name, p.typ)
+		}
+		text, err := formatParam(p.typ, value)
+		if err != nil {
+			return nil, fmt.Errorf("参数%s(类型%s): %w", p.name, p.typ, err)
+		}
+		bound[p.name] = text
+	}
+
+	var unused []string
+	for name := range params {
+		if _, ok := bound[name]; !ok {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, fmt.Errorf("查询中没有参数%s的占位符", strings.Join(unused, ", "))
+	}
+	return bound, nil
+}
+
+// escapedText ClickHouse TabSeparated(Escaped)格式的转义规则，参数值按该格式解析
+var escapedText = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// textParams String类型参数的原始值，如库名和表名
+type textParams map[string]string
+
+// escaped 按Escaped格式转义参数值
+func (p textParams) escaped() clickhouse.Parameters {
+	params := make(clickhouse.Parameters, len(p))
+	for name, value := range p {
+		params[name] = escapedText.Replace(value)
+	}
+	return params
+}
+
+// withParameters 将按Escaped格式书写的参数附加到ctx，并按协议编码
+func (c *DefaultClient) withParameters(ctx context.Context, params clickhouse.Parameters) context.Context {
+	return clickhouse.Context(ctx, clickhouse.WithParameters(encodeParams(params, c.protocol)))
+}
+
+// encodeParams 按协议编码Escaped格式的参数值。HTTP接口的param_*按原样作为Escaped文本解析；
+// 原生协议中驱动把值放在单引号中(只转义单引号)，服务端先按Quoted格式去掉一层转义，
+// 再按Escaped格式解析，因此反斜杠需要再转义一次
+func encodeParams(params clickhouse.Parameters, protocol Protocol) clickhouse.Parameters {
+	if protocol == ProtocolHTTP {
+		return params
+	}
+	encoded := make(clickhouse.Parameters, len(params))
+	for name, value := range params {
+		encoded[name] = strings.ReplaceAll(value, `\`, `\\`)
+	}
+	return encoded
+}
+
+// formatParam 将参数值转换为ClickHouse按Escaped格式解析的文本
+func formatParam(t *Type, v any) (string, error) {
+	if v == nil {
+		if !t.IsNullable() {
+			return "", fmt.Errorf("不能为null")
+		}
+		return `\N`, nil
+	}
+
+	u := t.Unwrap()
+	switch u.Name {
+	case "Array", "Map", "Tuple":
+		// 复合类型的元素按Quoted格式书写
+		return quoteParam(u, v)
+	}
+	if s, ok := v.(string); ok && isTextParam(u) {
+		return escapedText.Replace(s), nil
+	}
+	return scalarParam(u, v)
+}
+
+// quoteParam 将参数值转换为Quoted格式，用于数组、Map和Tuple的元素
+func quoteParam(t *Type, v any) (string, error) {
+	if v == nil {
+		if !t.IsNullable() {
+			return "", fmt.Errorf("不能为null")
+		}
+		return "NULL", nil
+	}
+
+	u := t.Unwrap()
+	switch u.Name {
+	case "Array":
+		items, ok := v.([]any)
+		if !ok || len(u.Elems) != 1 {
+			return "", fmt.Errorf("值必须是数组，实际为%s", jsonKind(v))
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			part, err := quoteParam(u.Elems[0], item)
+			if err != nil {
+				return "", fmt.Errorf("第%d个元素: %w", i+1, err)
+			}
+			parts[i] = part
+		}
+		return "[" + strings.Join(parts, ",") + "]", nil
+	case "Tuple":
+		items, ok := v.([]any)
+		if !ok || len(items) != len(u.Elems) {
+			return "", fmt.Errorf("值必须是包含%d个元素的数组", len(u.Elems))
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			part, err := quoteParam(u.Elems[i], item)
+			if err != nil {
+				return "", fmt.Errorf("第%d个元素: %w", i+1, err)
+			}
+			parts[i] = part
+		}
+		return "(" + strings.Join(parts, ",") + ")", nil
+	case "Map":
+		entries, ok := v.(map[string]any)
+		if !ok || len(u.Elems) != 2 {
+			return "", fmt.Errorf("值必须是对象，实际为%s", jsonKind(v))
+		}
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			k, err := quoteParam(u.Elems[0], key)
+			if err != nil {
+				return "", fmt.Errorf("键%q: %w", key, err)
+			}
+			val, err := quoteParam(u.Elems[1], entries[key])
+			if err != nil {
+				return "", fmt.Errorf("键%q: %w", key, err)
+			}
+			parts[i] = k + ":" + val
+		}
+		return "{" + strings.Join(parts, ",") + "}", nil
+	}
+
+	if s, ok := v.(string); ok && isTextParam(u) {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'", nil
+	}
+	return scalarParam(u, v)
+}
+
+// isTextParam 类型的值是否以字符串书写: 字符串、日期时间、UUID、IP、Enum、标识符等
+func isTextParam(t *Type) bool {
+	switch {
+	case t.Name == "Bool", strings.HasPrefix(t.Name, "Decimal"), isIntegerType(t.Name), isFloatType(t.Name):
+		return false
+	}
+	return true
+}
+
+// scalarParam 转换数值和布尔参数，数值可以是JSON数字或数字字符串(大整数不丢失精度)
+func scalarParam(t *Type, v any) (string, error) {
+	switch {
+	case t.Name == "Bool":
+		b, ok := v.(bool)
+		if !ok {
+			return "", fmt.Errorf("值必须是布尔值，实际为%s", jsonKind(v))
+		}
+		return strconv.FormatBool(b), nil
+	case isIntegerType(t.Name):
+		n, ok := new(big.Int), false
+		switch val := v.(type) {
+		case float64:
+			if val == math.Trunc(val) && !math.IsInf(val, 0) {
+				n, _ = new(big.Float).SetFloat64(val).Int(nil)
+				ok = true
+			}
+		case json.Number:
+			n, ok = n.SetString(val.String(), 10)
+		case string:
+			n, ok = n.SetString(strings.TrimSpace(val), 10)
+		}
+		if !ok {
+			return "", fmt.Errorf("值必须是整数，实际为%s", jsonKind(v))
+		}
+		if n.Sign() < 0 && strings.HasPrefix(t.Name, "UInt") {
+			return "", fmt.Errorf("值不能为负数")
+		}
+		return n.String(), nil
+	case isFloatType(t.Name):
+		switch val := v.(type) {
+		case float64:
+			return strconv.FormatFloat(val, 'g', -1, 64), nil
+		case json.Number:
+			return val.String(), nil
+		case string:
+			if _, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+				return strings.TrimSpace(val), nil
+			}
+		}
+		return "", fmt.Errorf("值必须是数字，实际为%s", jsonKind(v))
+	case strings.HasPrefix(t.Name, "Decimal"):
+		var text string
+		switch val := v.(type) {
+		case float64:
+			return decimal.NewFromFloat(val).String(), nil
+		case json.Number:
+			text = val.String()
+		case string:
+			text = strings.TrimSpace(val)
+		}
+		d, err := decimal.NewFromString(text)
+		if err != nil {
+			return "", fmt.Errorf("值必须是十进制数，实际为%s", jsonKind(v))
+		}
+		return d.String(), nil
+	}
+	return "", fmt.Errorf("值必须是字符串，实际为%s", jsonKind(v))
+}
+
+// isIntegerType 是否为整数类型Int8..Int256或UInt8..UInt256
+func isIntegerType(name string) bool {
+	bits, ok := strings.CutPrefix(strings.TrimPrefix(name, "U"), "Int")
+	return ok && isNumeric(bits)
+}
+
+// isFloatType 是否为浮点类型
+func isFloatType(name string) bool {
+	return name == "Float32" || name == "Float64" || name == "BFloat16"
+}
+
+// jsonKind 返回JSON值的类型名称，用于错误信息
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "布尔值"
+	case float64, json.Number:
+		return "数字"
+	case string:
+		return "字符串"
+	case []any:
+		return "数组"
+	case map[string]any:
+		return "对象"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,205 @@
+package clickhouse
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestFindPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "Простые плейсхолдеры",
+			query: "SELECT * FROM {tbl:Identifier} WHERE id = {id:UInt64} AND id != {id: UInt64}",
+			want:  []string{"tbl:Identifier", "id:UInt64"},
+		},
+		{
+			name:  "Составной тип",
+			query: "SELECT {m:Map(String, Array(Nullable(Int32)))}",
+			want:  []string{"m:Map(String, Array(Nullable(Int32)))"},
+		},
+		{
+			name:  "Строки, комментарии и литерал Map",
+			query: "SELECT '{a:UInt8}', {'k': 1} -- {b:UInt8}",
+		},
+		{name: "Незакрытый плейсхолдер", query: "SELECT {a:UInt8", wantErr: "不完整"},
+		{name: "Пустой тип", query: "SELECT {a:}", wantErr: "不完整"},
+		{name: "Некорректный тип", query: "SELECT {a:Array(UInt8}", wantErr: "类型"},
+		{name: "Разные типы", query: "SELECT {a:UInt8}, {a:String}", wantErr: "类型不一致"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findPlaceholders(tt.query)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("findPlaceholders() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findPlaceholders() error = %v", err)
+			}
+			var names []string
+			for _, p := range got {
+				names = append(names, p.name+":"+p.typ.String())
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("findPlaceholders() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatParam(t *testing.T) {
+	tests := []struct {
+		typ     string
+		value   any
+		want    string
+		wantErr bool
+	}{
+		{typ: "String", value: "a\tb\\c'd", want: `a\tb\\c'd`},
+		{typ: "Identifier", value: "events", want: "events"},
+		{typ: "DateTime", value: "2024-01-02 03:04:05", want: "2024-01-02 03:04:05"},
+		{typ: "UInt64", value: float64(42), want: "42"},
+		{typ: "UInt64", value: "18446744073709551615", want: "18446744073709551615"},
+		{typ: "Int128", value: json.Number("-170141183460469231731687303715884105728"), want: "-170141183460469231731687303715884105728"},
+		{typ: "Float64", value: 1.5, want: "1.5"},
+		{typ: "Decimal(18, 4)", value: "123.4500", want: "123.45"},
+		{typ: "Bool", value: true, want: "true"},
+		{typ: "Nullable(String)", value: nil, want: `\N`},
+		{typ: "LowCardinality(String)", value: "x", want: "x"},
+		{typ: "Array(String)", value: []any{"a'b", `c\d`}, want: `['a\'b','c\\d']`},
+		{typ: "Array(Nullable(UInt8))", value: []any{float64(1), nil}, want: "[1,NULL]"},
+		{typ: "Map(String, UInt8)", value: map[string]any{"b": float64(2), "a": float64(1)}, want: "{'a':1,'b':2}"},
+		{typ: "Tuple(String, Float64)", value: []any{"x", 0.5}, want: "('x',0.5)"},
+		{typ: "UInt8", value: 1.5, wantErr: true},
+		{typ: "UInt8", value: float64(-1), wantErr: true},
+		{typ: "UInt8", value: "abc", wantErr: true},
+		{typ: "String", value: float64(1), wantErr: true},
+		{typ: "String", value: nil, wantErr: true},
+		{typ: "Bool", value: "true", wantErr: true},
+		{typ: "Array(UInt8)", value: "[1,2]", wantErr: true},
+		{typ: "Tuple(UInt8, UInt8)", value: []any{float64(1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			typ, err := ParseType(tt.typ)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			got, err := formatParam(typ, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatParam(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("formatParam(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindParams(t *testing.T) {
+	query := "SELECT * FROM t WHERE id = {id:UInt64} AND name = {name:String}"
+
+	t.Run("Все параметры", func(t *testing.T) {
+		got, err := bindParams(query, map[string]any{"id": float64(7), "name": "x"})
+		if err != nil {
+			t.Fatalf("bindParams() error = %v", err)
+		}
+		want := clickhouse.Parameters{"id": "7", "name": "x"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("bindParams() = %v, want %v", got, want)
+		}
+	})
+
+	errTests := []struct {
+		name    string
+		query   string
+		params  map[string]any
+		wantErr string
+	}{
+		{name: "Нет параметра", query: query, params: map[string]any{"id": float64(7)}, wantErr: "缺少参数name(类型String)"},
+		{name: "Лишний параметр", query: "SELECT 1", params: map[string]any{"id": float64(7)}, wantErr: "没有参数id的占位符"},
+		{name: "Несовпадение типа", query: query, params: map[string]any{"id": "abc", "name": "x"}, wantErr: "参数id(类型UInt64): 值必须是整数"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bindParams(tt.query, tt.params)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("bindParams() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// readQuoted снимает экранирование обратной косой чертой, как readQuoted на сервере (упрощённо: \X -> X,
+// этого достаточно, когда все обратные косые черты в значении экранированы)
+func readQuoted(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// serverText повторяет путь значения параметра до сервера и возвращает текст, который сервер разберёт
+// в формате Escaped: в нативном протоколе драйвер заключает значение в кавычки, экранируя только кавычки,
+// и сервер читает его как Quoted; param_* HTTP-интерфейса передаётся как есть
+func serverText(value string, protocol Protocol) string {
+	if protocol == ProtocolHTTP {
+		return value
+	}
+	return readQuoted(strings.ReplaceAll(value, "'", `\'`))
+}
+
+func TestEncodeParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		typ        string
+		value      any
+		wantNative string
+		wantHTTP   string
+	}{
+		{name: "Строка с обратной косой чертой", typ: "String", value: `C:\tmp`, wantNative: `C:\\\\tmp`, wantHTTP: `C:\\tmp`},
+		{name: "Перевод строки и табуляция", typ: "String", value: "a\n\tb", wantNative: `a\\n\\tb`, wantHTTP: `a\n\tb`},
+		{name: "Кавычка", typ: "String", value: "it's", wantNative: "it's", wantHTTP: "it's"},
+		{name: "Массив строк", typ: "Array(String)", value: []any{`a'b`, `c\d`}, wantNative: `['a\\'b','c\\\\d']`, wantHTTP: `['a\'b','c\\d']`},
+		{name: "NULL", typ: "Nullable(String)", value: nil, wantNative: `\\N`, wantHTTP: `\N`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := ParseType(tt.typ)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			value, err := formatParam(typ, tt.value)
+			if err != nil {
+				t.Fatalf("formatParam() error = %v", err)
+			}
+			for protocol, want := range map[Protocol]string{ProtocolNative: tt.wantNative, ProtocolHTTP: tt.wantHTTP} {
+				got := encodeParams(clickhouse.Parameters{"p": value}, protocol)["p"]
+				if got != want {
+					t.Errorf("%s: encodeParams() = %q, want %q", protocol, got, want)
+				}
+				// Сервер должен разобрать в формате Escaped ровно то, что вернул formatParam
+				if text := serverText(got, protocol); text != value {
+					t.Errorf("%s: сервер разберёт %q, want %q", protocol, text, value)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package clickhouse
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConnection 未配置命名连接时唯一连接的名称
+const DefaultConnection = "default"
+
+// connectionName 连接名称的格式
+var connectionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateConnectionName 检查连接名称是否只包含字母、数字、下划线、点和连字符
+func ValidateConnectionName(name string) error {
+	if !connectionName.MatchString(name) {
+		return fmt.Errorf("无效的连接名称%q: 只能包含字母、数字、下划线、点和连字符，且以字母或数字开头", name)
+	}
+	return nil
+}
+
+// Profile 命名的连接配置
+type Profile struct {
+	Name   string
+	Config Config
+}
+
+// ConnectionStatus 连接的健康状态，由Registry.Status返回
+type ConnectionStatus struct {
+	Name     string   `json:"name"`
+	Default  bool     `json:"default,omitempty"`
+	Protocol Protocol `json:"protocol"`
+	Addr     []string `json:"addr"`
+	Database string   `json:"database"`
+	Secure   bool     `json:"secure"`
+	ReadOnly bool     `json:"readonly"`
+	Healthy  bool     `json:"healthy"`
+	// LatencyMs Ping的耗时(毫秒)
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	// ServerVersion、Timezone 服务端版本和时区，连接不可用时为空
+	ServerVersion string `json:"server_version,omitempty"`
+	Timezone      string `json:"timezone,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Registry 命名连接的注册表。每个连接的客户端在第一次使用时创建，之后复用其连接池
+// 直到Close；创建失败时不缓存错误，下次使用时重新创建
+type Registry struct {
+	profiles    map[string]Profile
+	names       []string
+	defaultName string
+	// newClient 创建客户端，默认为NewClient
+	newClient func(context.Context, Config) (Client, error)
+
+	mu      sync.Mutex
+	entries map[string]*registryEntry
+	closed  bool
+}
+
+// registryEntry 一个连接的客户端。创建期间pending非nil，同一连接的其他调用等待它完成，
+// 创建时不持有锁，因此不可用的连接不会阻塞其他连接和Close
+type registryEntry struct {
+	client  Client
+	pending *clientFlight
+}
+
+// clientFlight 正在进行的一次客户端创建，done在创建结束后关闭
+type clientFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRegistry 创建连接注册表，defaultName为不指定连接时使用的连接，为空时使用唯一的连接
+func NewRegistry(profiles []Profile, defaultName string) (*Registry, error) {
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("没有配置ClickHouse连接")
+	}
+	r := &Registry{
+		profiles:  make(map[string]Profile, len(profiles)),
+		newClient: NewClient,
+		entries:   map[string]*registryEntry{},
+	}
+	for _, profile := range profiles {
+		if err := ValidateConnectionName(profile.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := r.profiles[profile.Name]; ok {
+			return nil, fmt.Errorf("连接%s重复", profile.Name)
+		}
+		r.profiles[profile.Name] = profile
+		r.names = append(r.names, profile.Name)
+	}
+
+	switch {
+	case defaultName != "":
+		if _, ok := r.profiles[defaultName]; !ok {
+			return nil, fmt.Errorf("默认连接%s不存在(可用: %s)", defaultName, strings.Join(r.names, ", "))
+		}
+		r.defaultName = defaultName
+	case len(profiles) == 1:
+		r.defaultName = profiles[0].Name
+	default:
+		return nil, fmt.Errorf("配置了多个连接时必须指定默认连接(可用: %s)", strings.Join(r.names, ", "))
+	}
+	return r, nil
+}
+
+// Names 按配置顺序返回连接名称
+func (r *Registry) Names() []string {
+	return r.names
+}
+
+// Default 返回默认连接的名称
+func (r *Registry) Default() string {
+	return r.defaultName
+}
+
+// Client 返回名为name的连接的客户端，name为空时返回默认连接的客户端。
+// 客户端由第一个调用在ctx内创建，同时到达的调用等待其结果，ctx取消时放弃等待
+func (r *Registry) Client(ctx context.Context, name string) (Client, error) {
+	name = cmp.Or(name, r.defaultName)
+	profile, ok := r.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的连接%q(可用: %s)", name, strings.Join(r.names, ", "))
+	}
+
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("连接注册表已关闭")
+		}
+		entry, ok := r.entries[name]
+		if !ok {
+			entry = &registryEntry{}
+			r.entries[name] = entry
+		}
+		if entry.client != nil {
+			r.mu.Unlock()
+			return entry.client, nil
+		}
+		flight := entry.pending
+		if flight == nil {
+			flight = &clientFlight{done: make(chan struct{})}
+			entry.pending = flight
+			r.mu.Unlock()
+			return r.create(ctx, name, profile, entry, flight)
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("连接%s: %w", name, ctx.Err())
+		case <-flight.done:
+		}
+		// 创建者的ctx被取消时由等待者重新创建，其他错误直接返回，不缓存
+		if flight.err != nil && !errors.Is(flight.err, context.Canceled) && !errors.Is(flight.err, context.DeadlineExceeded) {
+			return nil, flight.err
+		}
+	}
+}
+
+// create 在ctx内创建连接的客户端并结束flight，创建失败时不缓存错误
+func (r *Registry) create(ctx context.Context, name string, profile Profile, entry *registryEntry, flight *clientFlight) (Client, error) {
+	client, err := r.newClient(ctx, profile.Config)
+	if err != nil {
+		err = fmt.Errorf("连接%s: %w", name, err)
+	}
+
+	r.mu.Lock()
+	entry.pending = nil
+	// 创建期间注册表可能已关闭，此时新客户端不会再被Close关闭
+	if err == nil && r.closed {
+		client.Close()
+		err = fmt.Errorf("连接注册表已关闭")
+	}
+	if err == nil {
+		entry.client = client
+	}
+	flight.err = err
+	r.mu.Unlock()
+	close(flight.done)
+
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Status 并发检查所有连接，按配置顺序返回状态。尚未创建的客户端会被创建并保留
+func (r *Registry) Status(ctx context.Context) []ConnectionStatus {
+	statuses := make([]ConnectionStatus, len(r.names))
+	var wg sync.WaitGroup
+	for i, name := range r.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = r.status(ctx, r.profiles[name])
+		}()
+	}
+	wg.Wait()
+	return statuses
+}
+
+// status 检查一个连接: Ping服务端并读取版本和时区
+func (r *Registry) status(ctx context.Context, profile Profile) ConnectionStatus {
+	cfg := profile.Config
+	status := ConnectionStatus{
+		Name:     profile.Name,
+		Default:  profile.Name == r.defaultName,
+		Protocol: cmp.Or(cfg.Protocol, ProtocolNative),
+		Addr:     cfg.Addr,
+		Database: cfg.Database,
+		Secure:   cfg.Secure,
+		ReadOnly: cfg.ReadOnly,
+	}
+
+	client, err := r.Client(ctx, profile.Name)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	conn := client.GetConnection()
+	start := time.Now()
+	if err := conn.Ping(ctx); err != nil {
+		status.Error = serverError(err).Error()
+		return status
+	}
+	status.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	status.Healthy = true
+
+	if version, err := conn.ServerVersion(); err == nil {
+		status.ServerVersion = version.Version.String()
+		if version.Timezone != nil {
+			status.Timezone = version.Timezone.String()
+		}
+	}
+	return status
+}
+
+// Close 关闭所有已创建的客户端，之后Client返回错误
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	entries := r.entries
+	r.entries = map[string]*registryEntry{}
+	r.mu.Unlock()
+
+	var errs []error
+	for name, entry := range entries {
+		if entry.client != nil {
+			if err := entry.client.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("关闭连接%s失败: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,240 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/proto"
+)
+
+// registryConn - тестовое соединение с заданной ошибкой Ping, версией сервера и признаком закрытия
+type registryConn struct {
+	driver.Conn
+	pingErr error
+	version string
+	closed  bool
+}
+
+func (c *registryConn) Ping(context.Context) error { return c.pingErr }
+
+func (c *registryConn) ServerVersion() (*driver.ServerVersion, error) {
+	return &driver.ServerVersion{Version: proto.ParseVersion(c.version), Timezone: time.UTC}, nil
+}
+
+func (c *registryConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestNewRegistry(t *testing.T) {
+	profiles := []Profile{{Name: "dev"}, {Name: "prod-eu"}}
+
+	tests := []struct {
+		name        string
+		profiles    []Profile
+		defaultName string
+		want        string
+		wantErr     string
+	}{
+		{name: "Единственное подключение по умолчанию", profiles: profiles[:1], want: "dev"},
+		{name: "Явное подключение по умолчанию", profiles: profiles, defaultName: "prod-eu", want: "prod-eu"},
+		{name: "Несколько подключений без умолчания", profiles: profiles, wantErr: "默认连接"},
+		{name: "Неизвестное подключение по умолчанию", profiles: profiles, defaultName: "stage", wantErr: "stage"},
+		{name: "Нет подключений", wantErr: "没有配置"},
+		{name: "Повторяющееся имя", profiles: []Profile{{Name: "dev"}, {Name: "dev"}}, wantErr: "重复"},
+		{name: "Некорректное имя", profiles: []Profile{{Name: "prod eu"}}, wantErr: "无效的连接名称"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := NewRegistry(tt.profiles, tt.defaultName)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewRegistry() error = %v, ожидалось %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRegistry() error = %v", err)
+			}
+			if registry.Default() != tt.want {
+				t.Errorf("Default() = %q, ожидалось %q", registry.Default(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistryClient(t *testing.T) {
+	registry, err := NewRegistry([]Profile{
+		{Name: "dev", Config: Config{Addr: []string{"dev:9000"}}},
+		{Name: "prod", Config: Config{Addr: []string{"prod:9000"}}},
+	}, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu      sync.Mutex
+		created = map[string]int{}
+		conns   []*registryConn
+		fail    = true
+	)
+	registry.newClient = func(_ context.Context, cfg Config) (Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if cfg.Addr[0] == "prod:9000" && fail {
+			fail = false
+			return nil, errors.New("connection refused")
+		}
+		created[cfg.Addr[0]]++
+		conn := &registryConn{}
+		conns = append(conns, conn)
+		return &DefaultClient{conn: conn}, nil
+	}
+
+	// Клиенты не создаются до первого использования
+	if len(created) != 0 {
+		t.Fatalf("клиенты созданы заранее: %v", created)
+	}
+
+	// Параллельные вызовы получают один и тот же клиент
+	var wg sync.WaitGroup
+	clients := make([]Client, 10)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clients[i], _ = registry.Client(context.Background(), "")
+		}()
+	}
+	wg.Wait()
+	for _, client := range clients {
+		if client == nil || client != clients[0] {
+			t.Fatalf("клиенты подключения по умолчанию различаются: %v", clients)
+		}
+	}
+	if created["dev:9000"] != 1 || created["prod:9000"] != 0 {
+		t.Errorf("создано клиентов: %v, ожидался один клиент dev", created)
+	}
+
+	// Ошибка создания не запоминается
+	if _, err := registry.Client(context.Background(), "prod"); err == nil || !strings.Contains(err.Error(), "连接prod") {
+		t.Errorf("Client(prod) error = %v, ожидалась ошибка подключения", err)
+	}
+	if _, err := registry.Client(context.Background(), "prod"); err != nil {
+		t.Errorf("повторный Client(prod) error = %v", err)
+	}
+
+	if _, err := registry.Client(context.Background(), "stage"); err == nil || !strings.Contains(err.Error(), "dev, prod") {
+		t.Errorf("Client(stage) error = %v, ожидался список подключений", err)
+	}
+
+	// Close закрывает созданные клиенты, после него новые не создаются
+	if err := registry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i, conn := range conns {
+		if !conn.closed {
+			t.Errorf("соединение %d не закрыто", i)
+		}
+	}
+	if _, err := registry.Client(context.Background(), "dev"); err == nil {
+		t.Error("Client() после Close без ошибки")
+	}
+}
+
+func TestRegistryClientPending(t *testing.T) {
+	registry, err := NewRegistry([]Profile{
+		{Name: "slow", Config: Config{Addr: []string{"slow:9000"}}},
+		{Name: "fast", Config: Config{Addr: []string{"fast:9000"}}},
+	}, "fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	registry.newClient = func(ctx context.Context, cfg Config) (Client, error) {
+		if cfg.Addr[0] == "slow:9000" {
+			started <- struct{}{}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-release:
+			}
+		}
+		return &DefaultClient{conn: &registryConn{}}, nil
+	}
+
+	// Первый вызов подключается, пока его контекст не отменён
+	creatorCtx, cancelCreator := context.WithCancel(context.Background())
+	creatorErr := make(chan error, 1)
+	go func() {
+		_, err := registry.Client(creatorCtx, "slow")
+		creatorErr <- err
+	}()
+	<-started
+
+	// Ожидающий вызов отказывается от ожидания по своему контексту
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := registry.Client(ctx, "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Client(slow) error = %v, ожидалось истечение контекста", err)
+	}
+
+	// Недоступное подключение не блокирует другие подключения
+	if _, err := registry.Client(context.Background(), "fast"); err != nil {
+		t.Errorf("Client(fast) error = %v", err)
+	}
+
+	// Отмена контекста прерывает подключение, ошибка не запоминается
+	cancelCreator()
+	if err := <-creatorErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Client(slow) error = %v, ожидалась отмена", err)
+	}
+	close(release)
+	if _, err := registry.Client(context.Background(), "slow"); err != nil {
+		t.Errorf("повторный Client(slow) error = %v", err)
+	}
+}
+
+func TestRegistryStatus(t *testing.T) {
+	registry, err := NewRegistry([]Profile{
+		{Name: "dev", Config: Config{Addr: []string{"dev:9000"}, Database: "default"}},
+		{Name: "stage", Config: Config{Protocol: ProtocolHTTP, Addr: []string{"stage:8443"}, Secure: true}},
+		{Name: "prod", Config: Config{Addr: []string{"prod:9000"}, ReadOnly: true}},
+	}, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry.newClient = func(_ context.Context, cfg Config) (Client, error) {
+		switch cfg.Addr[0] {
+		case "stage:8443":
+			return nil, errors.New("dial tcp: connection refused")
+		case "prod:9000":
+			return &DefaultClient{conn: &registryConn{version: "24.8.4.13"}}, nil
+		}
+		return &DefaultClient{conn: &registryConn{pingErr: errors.New("read: connection reset by peer")}}, nil
+	}
+
+	statuses := registry.Status(context.Background())
+	if len(statuses) != 3 {
+		t.Fatalf("Status() вернул %d подключений, ожидалось 3", len(statuses))
+	}
+
+	dev, stage, prod := statuses[0], statuses[1], statuses[2]
+	if dev.Name != "dev" || dev.Healthy || !strings.Contains(dev.Error, "connection reset") || dev.Protocol != ProtocolNative {
+		t.Errorf("dev: %+v", dev)
+	}
+	if stage.Name != "stage" || stage.Healthy || !strings.Contains(stage.Error, "connection refused") || stage.Protocol != ProtocolHTTP || !stage.Secure {
+		t.Errorf("stage: %+v", stage)
+	}
+	if prod.Name != "prod" || !prod.Healthy || !prod.Default || !prod.ReadOnly || prod.ServerVersion != "24.8.4" || prod.Timezone != "UTC" || prod.Error != "" {
+		t.Errorf("prod: %+v", prod)
+	}
+}
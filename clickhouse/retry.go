@@ -0,0 +1,129 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// RetryPolicy 只读操作遇到暂时性错误时的重试策略
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数(含第一次)，小于等于1时不重试
+	MaxAttempts int
+	// InitialBackoff 第一次重试前的等待时间，之后每次翻倍
+	InitialBackoff time.Duration
+	// MaxBackoff 等待时间的上限，0表示不限制
+	MaxBackoff time.Duration
+}
+
+// retryableCodes 可以重试的服务端错误码: 网络故障、副本不可用和并发查询过多
+var retryableCodes = map[int32]bool{
+	3:   true, // UNEXPECTED_END_OF_FILE
+	32:  true, // ATTEMPT_TO_READ_AFTER_EOF
+	198: true, // DNS_ERROR
+	202: true, // TOO_MANY_SIMULTANEOUS_QUERIES
+	209: true, // SOCKET_TIMEOUT
+	210: true, // NETWORK_ERROR
+	279: true, // ALL_CONNECTION_TRIES_FAILED
+	285: true, // TOO_FEW_LIVE_REPLICAS
+	519: true, // NO_REMOTE_SHARD_AVAILABLE
+}
+
+// isRetryable 判断错误是否为暂时性错误，重试可能成功。
+// 上下文取消和超时不重试
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return retryableCodes[exception.Code]
+	}
+
+	// 连接被重置或关闭、建立连接失败、连接池已满
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed) || errors.Is(err, clickhouse.ErrAcquireConnTimeout) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isReadOnlyQuery 查询的所有语句是否都是只读语句，只有只读查询可以安全地重试
+func isReadOnlyQuery(query string) bool {
+	statements := ClassifyStatements(query)
+	for _, stmt := range statements {
+		if stmt.Kind != StatementRead {
+			return false
+		}
+	}
+	return len(statements) > 0
+}
+
+// backoff 返回第attempt次重试(从1开始)前的等待时间: 指数增长，加入随机抖动，
+// 避免多个客户端同时重试
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	// MaxBackoff为0时不限制上限，翻倍到接近Duration的最大值为止，避免溢出
+	for i := 1; i < attempt && (p.MaxBackoff == 0 || d < p.MaxBackoff) && d <= math.MaxInt64/2; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// 等待时间在[d/2, d)之间
+	return d/2 + rand.N(d-d/2)
+}
+
+// retry 执行fn，遇到可重试的错误时按策略等待后重试。
+// 返回fn最后一次的错误和重试次数，只能用于没有副作用的只读操作
+func (c *DefaultClient) retry(ctx context.Context, fn func() error) (int, error) {
+	retries := 0
+	for {
+		err := fn()
+		if err == nil || retries+1 >= c.retryPolicy.MaxAttempts || !isRetryable(err) {
+			return retries, err
+		}
+		retries++
+
+		timer := time.NewTimer(c.retryPolicy.backoff(retries))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return retries, err
+		case <-timer.C:
+		}
+	}
+}
+
+// query 执行只读查询，查询开始前遇到暂时性错误时按策略重试
+func (c *DefaultClient) query(ctx context.Context, query string) (driver.Rows, error) {
+	var rows driver.Rows
+	_, err := c.retry(ctx, func() (err error) {
+		rows, err = c.conn.Query(ctx, query)
+		return err
+	})
+	return rows, err
+}
+
+// queryRow 执行只读的单行查询并扫描结果，遇到暂时性错误时按策略重试
+func (c *DefaultClient) queryRow(ctx context.Context, query string, dest ...any) error {
+	_, err := c.retry(ctx, func() error {
+		return c.conn.QueryRow(ctx, query).Scan(dest...)
+	})
+	return err
+}
@@ -0,0 +1,159 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Слишком много запросов", &clickhouse.Exception{Code: 202}, true},
+		{"Реплика недоступна", serverError(&clickhouse.Exception{Code: 519}), true},
+		{"Сетевая ошибка сервера", fmt.Errorf("查询执行失败: %w", &clickhouse.Exception{Code: 210}), true},
+		{"Неизвестная таблица", &clickhouse.Exception{Code: 60}, false},
+		{"Превышен лимит памяти", &clickhouse.Exception{Code: 241}, false},
+		{"Сброс соединения", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"Соединение закрыто", io.EOF, true},
+		{"Ошибка подключения", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"Таймаут чтения", &net.OpError{Op: "read", Err: errors.New("i/o timeout")}, false},
+		{"Пул соединений занят", clickhouse.ErrAcquireConnTimeout, true},
+		{"Отмена контекста", context.Canceled, false},
+		{"Прочая ошибка", errors.New("scan error"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %v, ожидалось %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{5, time.Second},
+		{20, time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 100 {
+			got := p.backoff(tt.attempt)
+			if got < tt.max/2 || got >= tt.max {
+				t.Fatalf("backoff(%d) = %v, ожидалось [%v, %v)", tt.attempt, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyBackoffUnlimited(t *testing.T) {
+	// MaxBackoff = 0 не ограничивает рост ожидания
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond}
+	for attempt, max := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 6: 3200 * time.Millisecond} {
+		got := p.backoff(attempt)
+		if got < max/2 || got >= max {
+			t.Errorf("backoff(%d) = %v, ожидалось [%v, %v)", attempt, got, max/2, max)
+		}
+	}
+
+	// Удвоение не переполняет Duration
+	if got := p.backoff(200); got < time.Duration(math.MaxInt64/8) {
+		t.Errorf("backoff(200) = %v, ожидалось не меньше %v", got, time.Duration(math.MaxInt64/8))
+	}
+}
+
+func TestQueryStreamRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	ok := &fakeRows{
+		columns: []fakeColumnType{{name: "id", typ: "UInt64", scan: reflect.TypeOf(uint64(0))}},
+		data:    [][]any{{uint64(1)}},
+	}
+	reset := &fakeRows{err: fmt.Errorf("read: %w", syscall.ECONNRESET)}
+	busy := &fakeRows{err: &clickhouse.Exception{Code: 202, Message: "Too many simultaneous queries"}}
+
+	t.Run("Чтение повторяется", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{reset, busy, ok}}
+		client := &DefaultClient{conn: conn, retryPolicy: policy}
+
+		result, err := client.QueryData(context.Background(), "SELECT id FROM t", QueryOptions{})
+		if err != nil {
+			t.Fatalf("QueryData() error = %v", err)
+		}
+		if len(result.Rows) != 1 || result.Stats.Retries != 2 || len(conn.queries) != 3 {
+			t.Errorf("QueryData() rows = %v, retries = %d, запросов %d", result.Rows, result.Stats.Retries, len(conn.queries))
+		}
+	})
+
+	t.Run("Попытки исчерпаны", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{reset}}
+		client := &DefaultClient{conn: conn, retryPolicy: policy}
+
+		_, err := client.QueryData(context.Background(), "SELECT id FROM t", QueryOptions{})
+		if !errors.Is(err, syscall.ECONNRESET) || len(conn.queries) != 3 {
+			t.Errorf("QueryData() error = %v, запросов %d", err, len(conn.queries))
+		}
+	})
+
+	t.Run("Неповторяемая ошибка", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{{err: &clickhouse.Exception{Code: 60}}}}
+		client := &DefaultClient{conn: conn, retryPolicy: policy}
+
+		_, err := client.QueryData(context.Background(), "SELECT id FROM t", QueryOptions{})
+		if err == nil || len(conn.queries) != 1 {
+			t.Errorf("QueryData() error = %v, запросов %d", err, len(conn.queries))
+		}
+	})
+
+	t.Run("Запись не повторяется", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{reset, ok}}
+		client := &DefaultClient{conn: conn, retryPolicy: policy}
+
+		_, err := client.QueryData(context.Background(), "INSERT INTO t SELECT 1", QueryOptions{})
+		if err == nil || len(conn.queries) != 1 {
+			t.Errorf("QueryData() error = %v, запросов %d", err, len(conn.queries))
+		}
+	})
+
+	t.Run("Метаданные повторяются", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{busy, explainRows("default")}}
+		client := &DefaultClient{conn: conn, retryPolicy: policy}
+
+		databases, err := client.GetDatabases(context.Background())
+		if err != nil || len(databases) != 1 || len(conn.queries) != 2 {
+			t.Errorf("GetDatabases() = %v, %v, запросов %d", databases, err, len(conn.queries))
+		}
+	})
+
+	t.Run("Отмена контекста прерывает ожидание", func(t *testing.T) {
+		conn := &fakeConn{responses: []*fakeRows{reset}}
+		client := &DefaultClient{conn: conn, retryPolicy: RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := client.QueryData(ctx, "SELECT id FROM t", QueryOptions{})
+		if err == nil || len(conn.queries) != 1 {
+			t.Errorf("QueryData() error = %v, запросов %d", err, len(conn.queries))
+		}
+	})
+}
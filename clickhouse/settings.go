@@ -0,0 +1,129 @@
+package clickhouse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// settingKind 查询设置值的类型
+type settingKind int
+
+const (
+	// settingUInt 非负整数，0在ClickHouse中通常表示不限制
+	settingUInt settingKind = iota
+	// settingBool 布尔值
+	settingBool
+)
+
+// querySettings 允许按调用修改的查询设置及其值类型
+var querySettings = map[string]settingKind{
+	"max_execution_time": settingUInt,
+	"max_memory_usage":   settingUInt,
+	"max_rows_to_read":   settingUInt,
+	"max_bytes_to_read":  settingUInt,
+	"max_threads":        settingUInt,
+	"use_query_cache":    settingBool,
+}
+
+// SettingLimit 单个设置的允许范围
+type SettingLimit struct {
+	// Max 整数设置的上限，0表示不限制。设置上限后不允许取值0(不限制)
+	Max uint64
+}
+
+// SettingsPolicy 允许调用方修改的查询设置及其上限，为空时不允许修改任何设置
+type SettingsPolicy map[string]SettingLimit
+
+// ParseSettingsPolicy 解析逗号分隔的设置列表，每项为"名称"或"名称=上限"，
+// 例如"max_execution_time=60,max_threads=8,use_query_cache"
+func ParseSettingsPolicy(list string) (SettingsPolicy, error) {
+	policy := SettingsPolicy{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, ceiling, hasCeiling := strings.Cut(item, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		kind, ok := querySettings[name]
+		if !ok {
+			return nil, fmt.Errorf("不支持的查询设置: %q(支持: %s)", name, strings.Join(settingNames(), ", "))
+		}
+
+		var limit SettingLimit
+		if hasCeiling {
+			if kind != settingUInt {
+				return nil, fmt.Errorf("设置%s不是整数，不能指定上限", name)
+			}
+			value, err := strconv.ParseUint(strings.TrimSpace(ceiling), 10, 64)
+			if err != nil || value == 0 {
+				return nil, fmt.Errorf("设置%s的上限无效: %q", name, ceiling)
+			}
+			limit.Max = value
+		}
+		policy[name] = limit
+	}
+	return policy, nil
+}
+
+// settingNames 返回支持按调用修改的设置名称
+func settingNames() []string {
+	names := make([]string, 0, len(querySettings))
+	for name := range querySettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Names 返回策略允许的设置名称
+func (p SettingsPolicy) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate 检查调用方传入的设置是否被允许且不超过上限，
+// 返回可直接发送给ClickHouse的设置值
+func (p SettingsPolicy) Validate(settings map[string]any) (map[string]any, error) {
+	validated := make(map[string]any, len(settings))
+	for name, value := range settings {
+		limit, ok := p[name]
+		if !ok {
+			if len(p) == 0 {
+				return nil, fmt.Errorf("不允许修改查询设置")
+			}
+			return nil, fmt.Errorf("不允许修改设置%s(允许: %s)", name, strings.Join(p.Names(), ", "))
+		}
+
+		switch querySettings[name] {
+		case settingBool:
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("设置%s的值必须是布尔值", name)
+			}
+			validated[name] = 0
+			if b {
+				validated[name] = 1
+			}
+		default:
+			n, ok := value.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) || n > math.MaxInt64 {
+				return nil, fmt.Errorf("设置%s的值必须是非负整数", name)
+			}
+			v := uint64(n)
+			if limit.Max > 0 && (v == 0 || v > limit.Max) {
+				return nil, fmt.Errorf("设置%s的值%d超出允许范围1..%d", name, v, limit.Max)
+			}
+			validated[name] = v
+		}
+	}
+	return validated, nil
+}
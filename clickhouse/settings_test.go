@@ -0,0 +1,85 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSettingsPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    SettingsPolicy
+		wantErr bool
+	}{
+		{name: "Пустой список", input: "", want: SettingsPolicy{}},
+		{
+			name:  "С ограничениями",
+			input: " max_execution_time=60, MAX_THREADS = 8 ,use_query_cache,max_rows_to_read",
+			want: SettingsPolicy{
+				"max_execution_time": {Max: 60},
+				"max_threads":        {Max: 8},
+				"use_query_cache":    {},
+				"max_rows_to_read":   {},
+			},
+		},
+		{name: "Неизвестная настройка", input: "readonly", wantErr: true},
+		{name: "Ограничение для логической настройки", input: "use_query_cache=1", wantErr: true},
+		{name: "Нулевое ограничение", input: "max_threads=0", wantErr: true},
+		{name: "Некорректное ограничение", input: "max_threads=many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSettingsPolicy(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSettingsPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseSettingsPolicy() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsPolicyValidate(t *testing.T) {
+	policy := SettingsPolicy{
+		"max_execution_time": {Max: 60},
+		"max_rows_to_read":   {},
+		"use_query_cache":    {},
+	}
+
+	tests := []struct {
+		name    string
+		policy  SettingsPolicy
+		input   map[string]any
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:   "Допустимые значения",
+			policy: policy,
+			input:  map[string]any{"max_execution_time": float64(30), "max_rows_to_read": float64(0), "use_query_cache": true},
+			want:   map[string]any{"max_execution_time": uint64(30), "max_rows_to_read": uint64(0), "use_query_cache": 1},
+		},
+		{name: "Выше ограничения", policy: policy, input: map[string]any{"max_execution_time": float64(61)}, wantErr: true},
+		{name: "Ноль при ограничении", policy: policy, input: map[string]any{"max_execution_time": float64(0)}, wantErr: true},
+		{name: "Дробное значение", policy: policy, input: map[string]any{"max_rows_to_read": 1.5}, wantErr: true},
+		{name: "Отрицательное значение", policy: policy, input: map[string]any{"max_rows_to_read": float64(-1)}, wantErr: true},
+		{name: "Строка вместо логического", policy: policy, input: map[string]any{"use_query_cache": "true"}, wantErr: true},
+		{name: "Не разрешено", policy: policy, input: map[string]any{"max_threads": float64(4)}, wantErr: true},
+		{name: "Пустой список запрещает всё", policy: SettingsPolicy{}, input: map[string]any{"max_threads": float64(4)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Validate(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
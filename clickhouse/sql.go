@@ -0,0 +1,125 @@
+package clickhouse
+
+import (
+	"strings"
+	"unicode"
+
+	"clickhouse-mcp/lexer"
+)
+
+// sqlStatement 表示按分号拆分出的一条语句
+type sqlStatement struct {
+	// text 语句原始文本，不含首尾的空白和注释
+	text string
+	// tokens 语句中有意义的词法单元(不含空白和注释)
+	tokens []lexer.Token
+}
+
+// splitStatements 按分号拆分查询，跳过字符串、标识符和注释中的分号，忽略空语句
+func splitStatements(query string) []sqlStatement {
+	var (
+		statements []sqlStatement
+		current    []lexer.Token
+	)
+
+	flush := func() {
+		if len(current) > 0 {
+			last := current[len(current)-1]
+			statements = append(statements, sqlStatement{
+				text:   query[current[0].Pos:last.End()],
+				tokens: current,
+			})
+		}
+		current = nil
+	}
+
+	for _, tok := range lexer.Tokenize(query) {
+		switch {
+		case !tok.Significant():
+			continue
+		case tok.IsPunct(";"):
+			// 括号内不允许出现分号，不平衡的括号同样按语句边界处理
+			flush()
+		default:
+			current = append(current, tok)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// isOpenBracket 是否为左括号
+func isOpenBracket(tok lexer.Token) bool {
+	return tok.IsPunct("(") || tok.IsPunct("[") || tok.IsPunct("{")
+}
+
+// isCloseBracket 是否为右括号
+func isCloseBracket(tok lexer.Token) bool {
+	return tok.IsPunct(")") || tok.IsPunct("]") || tok.IsPunct("}")
+}
+
+// topLevelTokens 返回括号外的词法单元，括号内容只保留左括号作为占位
+func topLevelTokens(tokens []lexer.Token) []lexer.Token {
+	var (
+		result []lexer.Token
+		depth  int
+	)
+	for _, tok := range tokens {
+		switch {
+		case isOpenBracket(tok):
+			if depth == 0 {
+				result = append(result, tok)
+			}
+			depth++
+		case isCloseBracket(tok):
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0:
+			result = append(result, tok)
+		}
+	}
+	return result
+}
+
+// normalizeQuery 规范化SQL查询: 去除首尾空白、末尾分号及其后的注释
+func normalizeQuery(query string) string {
+	tokens := lexer.Tokenize(query)
+
+	end := 0
+	for i := len(tokens) - 1; i >= 0; i-- {
+		tok := tokens[i]
+		if tok.Significant() && !tok.IsPunct(";") {
+			end = tok.End()
+			break
+		}
+	}
+
+	return strings.TrimLeftFunc(query[:end], unicode.IsSpace)
+}
+
+// endsWithSemicolon 检查是否以分号结尾(忽略末尾空白和注释)
+func endsWithSemicolon(query string) bool {
+	tokens := lexer.Significant(lexer.Tokenize(query))
+	return len(tokens) > 0 && tokens[len(tokens)-1].IsPunct(";")
+}
+
+// removeComments 移除SQL注释，块注释替换为空格，字符串中的注释符号保持不变
+func removeComments(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	for _, tok := range lexer.Tokenize(query) {
+		switch {
+		case tok.Type == lexer.BlockComment && !tok.Unterminated:
+			b.WriteByte(' ')
+		case tok.IsComment():
+			// 单行注释和未闭合的块注释直接丢弃
+		default:
+			b.WriteString(tok.Text)
+		}
+	}
+
+	return b.String()
+}
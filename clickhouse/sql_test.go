@@ -0,0 +1,231 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"clickhouse-mcp/lexer"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Запрос без точки с запятой",
+			query: "SELECT 1",
+			want:  "SELECT 1",
+		},
+		{
+			name:  "Запрос с точкой с запятой",
+			query: "SELECT 1;",
+			want:  "SELECT 1",
+		},
+		{
+			name:  "Запрос с лишними пробелами",
+			query: "  SELECT   1  ; ",
+			want:  "SELECT   1",
+		},
+		{
+			name:  "Пустой запрос",
+			query: "",
+			want:  "",
+		},
+		{
+			name:  "Только точка с запятой",
+			query: ";",
+			want:  "",
+		},
+		{
+			name:  "Комментарий после точки с запятой",
+			query: "SELECT 1; -- конец",
+			want:  "SELECT 1",
+		},
+		{
+			name:  "Точка с запятой внутри строки",
+			query: "SELECT ';'",
+			want:  "SELECT ';'",
+		},
+		{
+			name:  "Несколько точек с запятой",
+			query: "SELECT 1;;\n",
+			want:  "SELECT 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := normalizeQuery(tt.query)
+			if got != tt.want {
+				t.Errorf("normalizeQuery() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndsWithSemicolon(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{
+			name:  "Запрос без точки с запятой",
+			query: "SELECT 1",
+			want:  false,
+		},
+		{
+			name:  "Запрос с точкой с запятой",
+			query: "SELECT 1;",
+			want:  true,
+		},
+		{
+			name:  "Запрос с точкой с запятой и пробелами",
+			query: "SELECT 1  ;  ",
+			want:  true,
+		},
+		{
+			name:  "Пустой запрос",
+			query: "",
+			want:  false,
+		},
+		{
+			name:  "Только точка с запятой",
+			query: ";",
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := endsWithSemicolon(tt.query)
+			if got != tt.want {
+				t.Errorf("endsWithSemicolon() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveComments(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "Запрос без комментариев",
+			query: "SELECT * FROM table",
+			want:  "SELECT * FROM table",
+		},
+		{
+			name:  "Запрос с многострочным комментарием",
+			query: "SELECT * FROM table /* это комментарий */ WHERE id = 1",
+			want:  "SELECT * FROM table   WHERE id = 1",
+		},
+		{
+			name:  "Запрос с многострочным комментарием в конце",
+			query: "SELECT * FROM table /* это комментарий */",
+			want:  "SELECT * FROM table  ",
+		},
+		{
+			name:  "Запрос с незакрытым многострочным комментарием",
+			query: "SELECT * FROM table /* незакрытый комментарий",
+			want:  "SELECT * FROM table ",
+		},
+		{
+			name:  "Запрос с однострочным комментарием",
+			query: "SELECT * FROM table -- это комментарий\nWHERE id = 1",
+			want:  "SELECT * FROM table \nWHERE id = 1",
+		},
+		{
+			name:  "Запрос с несколькими комментариями",
+			query: "SELECT * /* внутри */ FROM table -- конец строки\nWHERE /* условие */ id = 1",
+			want:  "SELECT *   FROM table \nWHERE   id = 1",
+		},
+		{
+			name:  "Двойной дефис внутри строки",
+			query: "SELECT '--not a comment' -- comment",
+			want:  "SELECT '--not a comment' ",
+		},
+		{
+			name:  "Вложенный комментарий",
+			query: "SELECT /* a /* b */ c */ 1",
+			want:  "SELECT   1",
+		},
+		{
+			name:  "Комментарий с решёткой",
+			query: "SELECT 1 # comment\nFROM t",
+			want:  "SELECT 1 \nFROM t",
+		},
+		{
+			name:  "Комментарий внутри идентификатора",
+			query: "SELECT `a--b` FROM t",
+			want:  "SELECT `a--b` FROM t",
+		},
+		{
+			name:  "Комментарий внутри heredoc",
+			query: "SELECT $doc$ /* x */ $doc$",
+			want:  "SELECT $doc$ /* x */ $doc$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := removeComments(tt.query)
+			if got != tt.want {
+				t.Errorf("removeComments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	got := splitStatements("/* a */ SELECT 1; SELECT ';' -- b\n;; SELECT 2")
+	want := []string{"SELECT 1", "SELECT ';'", "SELECT 2"}
+
+	if len(got) != len(want) {
+		t.Fatalf("splitStatements() вернул %d выражений, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].text != want[i] {
+			t.Errorf("splitStatements()[%d].text = %q, want %q", i, got[i].text, want[i])
+		}
+	}
+}
+
+func FuzzNormalizeQuery(f *testing.F) {
+	for _, seed := range []string{"SELECT 1;", "  SELECT 1 ; -- c", "SELECT ';'", ";", "SELECT $$;$$;"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		normalized := normalizeQuery(query)
+		// Нормализация идемпотентна и не оставляет точку с запятой в конце
+		if again := normalizeQuery(normalized); again != normalized {
+			t.Errorf("normalizeQuery() не идемпотентна: %q -> %q", normalized, again)
+		}
+		if endsWithSemicolon(normalized) {
+			t.Errorf("normalizeQuery(%q) = %q заканчивается точкой с запятой", query, normalized)
+		}
+	})
+}
+
+func FuzzRemoveComments(f *testing.F) {
+	for _, seed := range []string{"SELECT 1 -- c", "/* a /* b */ */", "SELECT '--'", "# x\ny", "---", "/*/"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		cleaned := removeComments(query)
+		for _, tok := range lexer.Tokenize(cleaned) {
+			if tok.IsComment() {
+				t.Fatalf("removeComments(%q) = %q содержит комментарий %q", query, cleaned, tok.Text)
+			}
+		}
+		// Разбиение на выражения не зависит от комментариев
+		if len(splitStatements(query)) != len(splitStatements(cleaned)) {
+			t.Errorf("splitStatements() отличается для %q и %q", query, cleaned)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"clickhouse-mcp/lexer"
+)
+
+// StatementKind 表示SQL语句的类别
+type StatementKind int
+
+const (
+	// StatementUnknown 无法识别的语句
+	StatementUnknown StatementKind = iota
+	// StatementRead 只读语句: SELECT/SHOW/DESCRIBE/EXPLAIN/EXISTS
+	StatementRead
+	// StatementDDL 结构变更语句: CREATE/ALTER/DROP/RENAME/TRUNCATE等
+	StatementDDL
+	// StatementDML 数据变更语句: INSERT/DELETE/UPDATE以及ALTER ... DELETE/UPDATE
+	StatementDML
+	// StatementAdmin 管理语句: SYSTEM/KILL/OPTIMIZE/GRANT/SET等
+	StatementAdmin
+)
+
+// String 返回语句类别名称
+func (k StatementKind) String() string {
+	switch k {
+	case StatementRead:
+		return "read"
+	case StatementDDL:
+		return "ddl"
+	case StatementDML:
+		return "dml"
+	case StatementAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseStatementKind 根据名称解析语句类别
+func ParseStatementKind(name string) (StatementKind, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "read", "select":
+		return StatementRead, nil
+	case "ddl":
+		return StatementDDL, nil
+	case "dml":
+		return StatementDML, nil
+	case "admin":
+		return StatementAdmin, nil
+	default:
+		return StatementUnknown, fmt.Errorf("未知的语句类别: %q", name)
+	}
+}
+
+// ParseStatementKinds 解析逗号分隔的语句类别列表
+func ParseStatementKinds(list string) ([]StatementKind, error) {
+	var kinds []StatementKind
+	for _, name := range strings.Split(list, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		kind, err := ParseStatementKind(name)
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// Statement 描述一条已分类的SQL语句
+type Statement struct {
+	Kind    StatementKind
+	Keyword string
+	Text    string
+}
+
+// readKeywords 只读语句的起始关键字
+var readKeywords = map[string]bool{
+	"SELECT": true, "WITH": true, "SHOW": true, "DESCRIBE": true,
+	"DESC": true, "EXPLAIN": true, "EXISTS": true,
+}
+
+// ddlKeywords 结构变更语句的起始关键字
+var ddlKeywords = map[string]bool{
+	"CREATE": true, "ALTER": true, "DROP": true, "RENAME": true, "TRUNCATE": true,
+	"ATTACH": true, "DETACH": true, "EXCHANGE": true, "UNDROP": true,
+}
+
+// dmlKeywords 数据变更语句的起始关键字
+var dmlKeywords = map[string]bool{
+	"INSERT": true, "DELETE": true, "UPDATE": true,
+}
+
+// adminKeywords 管理语句的起始关键字
+var adminKeywords = map[string]bool{
+	"SYSTEM": true, "KILL": true, "OPTIMIZE": true, "GRANT": true, "REVOKE": true,
+	"SET": true, "USE": true, "CHECK": true, "BACKUP": true, "RESTORE": true, "MOVE": true,
+}
+
+// accessEntities 访问控制实体，CREATE/ALTER/DROP这些对象属于管理语句
+var accessEntities = map[string]bool{
+	"USER": true, "ROLE": true, "QUOTA": true, "ROW": true, "POLICY": true,
+	"SETTINGS": true, "PROFILE": true, "NAMED": true,
+}
+
+// danger 语句类别的危险程度: read < dml < ddl < admin < unknown，无法识别的语句无法判断其影响，视为最危险
+func (k StatementKind) danger() int {
+	switch k {
+	case StatementRead:
+		return 0
+	case StatementDML:
+		return 1
+	case StatementDDL:
+		return 2
+	case StatementAdmin:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// ClassifyStatement 对单条语句分类，多条语句时返回危险程度最高的那条，程度相同时返回靠前的
+func ClassifyStatement(query string) Statement {
+	statements := ClassifyStatements(query)
+	if len(statements) == 0 {
+		return Statement{Kind: StatementUnknown}
+	}
+	worst := statements[0]
+	for _, stmt := range statements[1:] {
+		if stmt.Kind.danger() > worst.Kind.danger() {
+			worst = stmt
+		}
+	}
+	return worst
+}
+
+// ClassifyStatements 按顶层分号拆分查询并逐条分类
+func ClassifyStatements(query string) []Statement {
+	var statements []Statement
+	for _, stmt := range splitStatements(query) {
+		statements = append(statements, classifyTokens(stmt))
+	}
+	return statements
+}
+
+// classifyTokens 根据语句的词法单元判断类别
+func classifyTokens(stmt sqlStatement) Statement {
+	// 语句可能以括号开头，如 "(SELECT 1) UNION ALL (SELECT 2)"
+	i := 0
+	for i < len(stmt.tokens) && stmt.tokens[i].IsPunct("(") {
+		i++
+	}
+	if i >= len(stmt.tokens) || stmt.tokens[i].Type != lexer.Word {
+		return Statement{Kind: StatementUnknown, Text: stmt.text}
+	}
+
+	first := strings.ToUpper(stmt.tokens[i].Text)
+	result := Statement{Keyword: first, Text: stmt.text}
+
+	switch {
+	case readKeywords[first]:
+		result.Kind = StatementRead
+	case dmlKeywords[first]:
+		result.Kind = StatementDML
+	case adminKeywords[first]:
+		result.Kind = StatementAdmin
+	case ddlKeywords[first]:
+		result.Kind = classifyDDL(first, topLevelTokens(stmt.tokens)[1:])
+	default:
+		result.Kind = StatementUnknown
+	}
+	return result
+}
+
+// classifyDDL 细分CREATE/ALTER/DROP等语句，识别访问控制语句和ALTER变更操作
+func classifyDDL(keyword string, rest []lexer.Token) StatementKind {
+	i := 0
+	// 跳过 CREATE OR REPLACE / CREATE TEMPORARY 等修饰
+	for i < len(rest) && (rest[i].IsKeyword("OR") || rest[i].IsKeyword("REPLACE") || rest[i].IsKeyword("TEMPORARY")) {
+		i++
+	}
+	if i < len(rest) && rest[i].Type == lexer.Word && accessEntities[strings.ToUpper(rest[i].Text)] {
+		return StatementAdmin
+	}
+	if keyword != "ALTER" || i >= len(rest) || !rest[i].IsKeyword("TABLE") {
+		return StatementDDL
+	}
+
+	// ALTER TABLE [db.]name [ON CLUSTER c] command [, command ...]
+	i += 2
+	for i+1 < len(rest) && rest[i].IsPunct(".") {
+		i += 2
+	}
+	if i+2 < len(rest) && rest[i].IsKeyword("ON") && rest[i+1].IsKeyword("CLUSTER") {
+		i += 3
+	}
+	for j := i; j < len(rest); j++ {
+		if j != i && !rest[j-1].IsPunct(",") {
+			continue
+		}
+		if rest[j].IsKeyword("DELETE") || rest[j].IsKeyword("UPDATE") {
+			return StatementDML
+		}
+	}
+	return StatementDDL
+}
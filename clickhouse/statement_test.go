@@ -0,0 +1,240 @@
+package clickhouse
+
+import (
+	"testing"
+)
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		wantKind    StatementKind
+		wantKeyword string
+	}{
+		{
+			name:        "Простой SELECT",
+			query:       "SELECT 1",
+			wantKind:    StatementRead,
+			wantKeyword: "SELECT",
+		},
+		{
+			name:        "SELECT в нижнем регистре с комментариями",
+			query:       "-- комментарий\n/* блок /* вложенный */ */ select * from t",
+			wantKind:    StatementRead,
+			wantKeyword: "SELECT",
+		},
+		{
+			name:        "Комментарий с решёткой",
+			query:       "# комментарий\nSHOW TABLES",
+			wantKind:    StatementRead,
+			wantKeyword: "SHOW",
+		},
+		{
+			name:        "WITH и SELECT",
+			query:       "WITH x AS (SELECT 1) SELECT * FROM x",
+			wantKind:    StatementRead,
+			wantKeyword: "WITH",
+		},
+		{
+			name:        "Запрос в скобках",
+			query:       "(SELECT 1) UNION ALL (SELECT 2)",
+			wantKind:    StatementRead,
+			wantKeyword: "SELECT",
+		},
+		{
+			name:        "DESCRIBE",
+			query:       "DESC TABLE system.one",
+			wantKind:    StatementRead,
+			wantKeyword: "DESC",
+		},
+		{
+			name:        "EXPLAIN",
+			query:       "EXPLAIN PIPELINE SELECT 1",
+			wantKind:    StatementRead,
+			wantKeyword: "EXPLAIN",
+		},
+		{
+			name:        "DROP TABLE",
+			query:       "DROP TABLE t",
+			wantKind:    StatementDDL,
+			wantKeyword: "DROP",
+		},
+		{
+			name:        "CREATE OR REPLACE TABLE",
+			query:       "CREATE OR REPLACE TABLE t (a UInt8) ENGINE = Memory",
+			wantKind:    StatementDDL,
+			wantKeyword: "CREATE",
+		},
+		{
+			name:        "ALTER ADD COLUMN",
+			query:       "ALTER TABLE db.t ADD COLUMN x UInt8",
+			wantKind:    StatementDDL,
+			wantKeyword: "ALTER",
+		},
+		{
+			name:        "ALTER DELETE",
+			query:       "ALTER TABLE db.t DELETE WHERE id = 1",
+			wantKind:    StatementDML,
+			wantKeyword: "ALTER",
+		},
+		{
+			name:        "ALTER UPDATE на кластере",
+			query:       "ALTER TABLE t ON CLUSTER '{cluster}' UPDATE x = 1 WHERE 1",
+			wantKind:    StatementDML,
+			wantKeyword: "ALTER",
+		},
+		{
+			name:        "ALTER с несколькими командами",
+			query:       "ALTER TABLE t DROP COLUMN a, DELETE WHERE b = 'DELETE'",
+			wantKind:    StatementDML,
+			wantKeyword: "ALTER",
+		},
+		{
+			name:        "TTL с DELETE остаётся DDL",
+			query:       "ALTER TABLE t MODIFY TTL d + INTERVAL 1 DAY DELETE",
+			wantKind:    StatementDDL,
+			wantKeyword: "ALTER",
+		},
+		{
+			name:        "INSERT",
+			query:       "INSERT INTO t VALUES (1)",
+			wantKind:    StatementDML,
+			wantKeyword: "INSERT",
+		},
+		{
+			name:        "Лёгкое удаление",
+			query:       "DELETE FROM t WHERE id = 1",
+			wantKind:    StatementDML,
+			wantKeyword: "DELETE",
+		},
+		{
+			name:        "SYSTEM",
+			query:       "SYSTEM DROP DNS CACHE",
+			wantKind:    StatementAdmin,
+			wantKeyword: "SYSTEM",
+		},
+		{
+			name:        "CREATE USER",
+			query:       "CREATE USER bob IDENTIFIED BY 'x'",
+			wantKind:    StatementAdmin,
+			wantKeyword: "CREATE",
+		},
+		{
+			name:        "KILL QUERY",
+			query:       "KILL QUERY WHERE query_id = 'x'",
+			wantKind:    StatementAdmin,
+			wantKeyword: "KILL",
+		},
+		{
+			name:        "Несколько выражений",
+			query:       "SELECT 1; DROP TABLE t",
+			wantKind:    StatementDDL,
+			wantKeyword: "DROP",
+		},
+		{
+			name:        "Самое опасное из нескольких выражений",
+			query:       "INSERT INTO t VALUES (1); ALTER USER u IDENTIFIED BY 'x'; CREATE TABLE t2 (a UInt8) ENGINE = Memory",
+			wantKind:    StatementAdmin,
+			wantKeyword: "ALTER",
+		},
+		{
+			name:        "Неизвестное выражение после изменения данных",
+			query:       "DELETE FROM t WHERE 1; FOO BAR",
+			wantKind:    StatementUnknown,
+			wantKeyword: "FOO",
+		},
+		{
+			name:        "Точка с запятой в строке",
+			query:       "SELECT ';DROP TABLE t'",
+			wantKind:    StatementRead,
+			wantKeyword: "SELECT",
+		},
+		{
+			name:        "Неизвестное выражение",
+			query:       "FOO BAR",
+			wantKind:    StatementUnknown,
+			wantKeyword: "FOO",
+		},
+		{
+			name:     "Пустой запрос",
+			query:    "  -- только комментарий",
+			wantKind: StatementUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClassifyStatement(tt.query)
+			if got.Kind != tt.wantKind {
+				t.Errorf("ClassifyStatement().Kind = %v, want %v", got.Kind, tt.wantKind)
+			}
+			if got.Keyword != tt.wantKeyword {
+				t.Errorf("ClassifyStatement().Keyword = %v, want %v", got.Keyword, tt.wantKeyword)
+			}
+		})
+	}
+}
+
+func TestClassifyStatements(t *testing.T) {
+	got := ClassifyStatements("SELECT 1; SELECT 2;; INSERT INTO t VALUES (';')")
+	want := []StatementKind{StatementRead, StatementRead, StatementDML}
+
+	if len(got) != len(want) {
+		t.Fatalf("ClassifyStatements() вернул %d выражений, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Kind != want[i] {
+			t.Errorf("ClassifyStatements()[%d].Kind = %v, want %v", i, got[i].Kind, want[i])
+		}
+	}
+	if got[1].Text != "SELECT 2" {
+		t.Errorf("ClassifyStatements()[1].Text = %q, want %q", got[1].Text, "SELECT 2")
+	}
+}
+
+func TestParseStatementKinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []StatementKind
+		wantErr bool
+	}{
+		{
+			name: "Один тип",
+			list: "read",
+			want: []StatementKind{StatementRead},
+		},
+		{
+			name: "Несколько типов с пробелами",
+			list: " read , DDL,dml ,admin",
+			want: []StatementKind{StatementRead, StatementDDL, StatementDML, StatementAdmin},
+		},
+		{
+			name: "Пустая строка",
+			list: "",
+			want: nil,
+		},
+		{
+			name:    "Неизвестный тип",
+			list:    "read,all",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatementKinds(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStatementKinds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseStatementKinds() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseStatementKinds()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
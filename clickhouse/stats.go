@@ -0,0 +1,93 @@
+package clickhouse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// peakMemoryEvent 服务端报告查询内存峰值的ProfileEvents指标
+const peakMemoryEvent = "MemoryTrackerPeakUsage"
+
+// QueryStats 查询执行统计，来自驱动的进度和ProfileEvents回调
+type QueryStats struct {
+	// QueryID 查询ID，可用于在system.query_log中查找该查询
+	QueryID string `json:"query_id"`
+	// ElapsedMs 从发送查询到读取结束的耗时(毫秒)
+	ElapsedMs float64 `json:"elapsed_ms"`
+	// ReadRows 服务端读取的行数，接口不报告进度时(HTTP)为空
+	ReadRows *uint64 `json:"read_rows,omitempty"`
+	// ReadBytes 服务端读取的未压缩字节数，接口不报告进度时(HTTP)为空
+	ReadBytes *uint64 `json:"read_bytes,omitempty"`
+	// ResultRows 返回给调用方的行数
+	ResultRows uint64 `json:"result_rows"`
+	// PeakMemoryBytes 查询的内存峰值(字节)，服务端未报告时为0
+	PeakMemoryBytes int64 `json:"peak_memory_bytes,omitempty"`
+	// Retries 因暂时性错误重试的次数，包括连接检查的重试
+	Retries int `json:"retries,omitempty"`
+}
+
+// statsCollector 收集查询执行统计。驱动在后台goroutine中调用回调，
+// 与读取结果的循环并发，因此需要加锁
+type statsCollector struct {
+	mu    sync.Mutex
+	start time.Time
+	stats QueryStats
+	// reportsProgress 接口是否报告进度，HTTP接口不传递进度包，读取量未知而不是0
+	reportsProgress     bool
+	readRows, readBytes uint64
+}
+
+// newStatsCollector 为指定查询ID创建统计收集器并开始计时
+func newStatsCollector(queryID string, protocol Protocol) *statsCollector {
+	return &statsCollector{
+		start:           time.Now(),
+		stats:           QueryStats{QueryID: queryID},
+		reportsProgress: protocol != ProtocolHTTP,
+	}
+}
+
+// context 返回附加了查询ID和统计回调的查询上下文
+func (s *statsCollector) context(ctx context.Context) context.Context {
+	return clickhouse.Context(ctx,
+		clickhouse.WithQueryID(s.stats.QueryID),
+		clickhouse.WithProgress(s.progress),
+		clickhouse.WithProfileEvents(s.profileEvents),
+	)
+}
+
+// progress 累加进度包中的读取量，每个进度包只包含自上一个包以来的增量
+func (s *statsCollector) progress(p *clickhouse.Progress) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readRows += p.Rows
+	s.readBytes += p.Bytes
+}
+
+// profileEvents 记录内存峰值，峰值是仪表值，取所有报告中的最大值
+func (s *statsCollector) profileEvents(events []clickhouse.ProfileEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, event := range events {
+		if event.Name == peakMemoryEvent && event.Value > s.stats.PeakMemoryBytes {
+			s.stats.PeakMemoryBytes = event.Value
+		}
+	}
+}
+
+// finish 停止计时并返回统计结果
+func (s *statsCollector) finish(resultRows, retries int) *QueryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	if s.reportsProgress {
+		readRows, readBytes := s.readRows, s.readBytes
+		stats.ReadRows, stats.ReadBytes = &readRows, &readBytes
+	}
+	stats.ElapsedMs = float64(time.Since(s.start).Microseconds()) / 1000
+	stats.ResultRows = uint64(resultRows)
+	stats.Retries = retries
+	return &stats
+}
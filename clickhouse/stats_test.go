@@ -0,0 +1,51 @@
+package clickhouse
+
+import (
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+func TestStatsCollector(t *testing.T) {
+	s := newStatsCollector("q1", ProtocolNative)
+
+	// Пакеты прогресса содержат приращения
+	s.progress(&clickhouse.Progress{Rows: 100, Bytes: 800})
+	s.progress(&clickhouse.Progress{Rows: 50, Bytes: 400})
+
+	// Пик памяти — показание, берётся максимальное значение
+	s.profileEvents([]clickhouse.ProfileEvent{
+		{Name: "MemoryTrackerUsage", Value: 9000},
+		{Name: peakMemoryEvent, Value: 4096},
+	})
+	s.profileEvents([]clickhouse.ProfileEvent{{Name: peakMemoryEvent, Value: 2048}})
+
+	got := s.finish(7, 2)
+
+	if got.QueryID != "q1" {
+		t.Errorf("QueryID = %q, want %q", got.QueryID, "q1")
+	}
+	if got.ReadRows == nil || got.ReadBytes == nil || *got.ReadRows != 150 || *got.ReadBytes != 1200 {
+		t.Errorf("ReadRows, ReadBytes = %v, %v, want 150, 1200", got.ReadRows, got.ReadBytes)
+	}
+	if got.ResultRows != 7 || got.Retries != 2 {
+		t.Errorf("ResultRows, Retries = %d, %d, want 7, 2", got.ResultRows, got.Retries)
+	}
+	if got.PeakMemoryBytes != 4096 {
+		t.Errorf("PeakMemoryBytes = %d, want 4096", got.PeakMemoryBytes)
+	}
+	if got.ElapsedMs < 0 {
+		t.Errorf("ElapsedMs = %v, want >= 0", got.ElapsedMs)
+	}
+}
+
+func TestStatsCollectorHTTP(t *testing.T) {
+	// HTTP-интерфейс не передаёт прогресс: объём чтения неизвестен, а не равен нулю
+	got := newStatsCollector("q1", ProtocolHTTP).finish(3, 0)
+	if got.ReadRows != nil || got.ReadBytes != nil {
+		t.Errorf("ReadRows, ReadBytes = %v, %v, want nil", got.ReadRows, got.ReadBytes)
+	}
+	if got.ResultRows != 3 {
+		t.Errorf("ResultRows = %d, want 3", got.ResultRows)
+	}
+}
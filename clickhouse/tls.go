@@ -0,0 +1,292 @@
+package clickhouse
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TLSConfig 使用TLS连接(Config.Secure)时的证书和校验配置
+type TLSConfig struct {
+	// CAFile PEM格式的CA证书文件，用于校验服务端证书，为空时使用系统证书
+	CAFile string
+	// CertFile、KeyFile PEM格式的客户端证书和私钥(mTLS)，需要同时配置
+	CertFile string
+	KeyFile  string
+	// ServerName 用于SNI和校验服务端证书的主机名，为空时使用连接地址中的主机名
+	ServerName string
+	// MinVersion 最低TLS版本，如tls.VersionTLS13，0表示TLS 1.2
+	MinVersion uint16
+	// InsecureSkipVerify 不校验服务端证书，只应在测试环境中使用
+	InsecureSkipVerify bool
+}
+
+// tlsVersions 支持的最低TLS版本
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion 解析TLS版本，如"1.2"或"TLS1.3"，空串返回0
+func ParseTLSVersion(name string) (uint16, error) {
+	version := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "tls")
+	if version == "" {
+		return 0, nil
+	}
+	if v, ok := tlsVersions[strings.TrimSpace(version)]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("未知的TLS版本: %q(支持: 1.0, 1.1, 1.2, 1.3)", name)
+}
+
+// fileStamp 文件的修改时间和大小，用于判断文件是否变化
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// tlsCredentials TLS配置及从磁盘加载的CA和客户端证书。
+// 建立新连接前检查文件，文件变化时重新加载，加载失败时继续使用原来的证书
+type tlsCredentials struct {
+	config TLSConfig
+
+	mu     sync.Mutex
+	roots  *x509.CertPool
+	cert   *tls.Certificate
+	stamps map[string]fileStamp
+}
+
+// newTLSCredentials 检查配置并加载证书
+func newTLSCredentials(cfg TLSConfig) (*tlsCredentials, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, fmt.Errorf("客户端证书和私钥需要同时配置")
+	}
+	if cfg.InsecureSkipVerify && cfg.CAFile != "" {
+		return nil, fmt.Errorf("不能同时配置CA证书和跳过证书校验")
+	}
+	c := &tlsCredentials{config: cfg}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	if cfg.InsecureSkipVerify {
+		slog.Warn("不校验ClickHouse服务端证书，连接可能被中间人攻击")
+	}
+	return c, nil
+}
+
+// files 需要加载的证书文件
+func (c *tlsCredentials) files() []string {
+	var files []string
+	for _, file := range []string{c.config.CAFile, c.config.CertFile, c.config.KeyFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// stat 返回证书文件当前的状态
+func (c *tlsCredentials) stat() (map[string]fileStamp, error) {
+	stamps := make(map[string]fileStamp)
+	for _, file := range c.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// load 加载CA和客户端证书
+func (c *tlsCredentials) load() error {
+	stamps, err := c.stat()
+	if err != nil {
+		return fmt.Errorf("读取TLS证书失败: %w", err)
+	}
+
+	var roots *x509.CertPool
+	if c.config.CAFile != "" {
+		pem, err := os.ReadFile(c.config.CAFile)
+		if err != nil {
+			return fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA证书文件%s中没有PEM格式的证书", c.config.CAFile)
+		}
+	}
+
+	var cert *tls.Certificate
+	if c.config.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(c.config.CertFile, c.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		cert = &pair
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roots, c.cert, c.stamps = roots, cert, stamps
+	return nil
+}
+
+// reload 证书文件变化时重新加载。文件正在被替换时可能读到不完整的内容，
+// 此时保留原来的证书，等文件再次变化时重新加载
+func (c *tlsCredentials) reload() {
+	stamps, err := c.stat()
+	c.mu.Lock()
+	changed := err != nil || len(stamps) != len(c.stamps)
+	for file, stamp := range stamps {
+		if old, ok := c.stamps[file]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = true
+		}
+	}
+	c.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if err := c.load(); err != nil {
+		slog.Warn("重新加载TLS证书失败，继续使用原来的证书", "err", err)
+		// 文件再次变化前不再重试，避免每个新连接都写日志
+		if stamps != nil {
+			c.mu.Lock()
+			c.stamps = stamps
+			c.mu.Unlock()
+		}
+		return
+	}
+	slog.Info("已重新加载TLS证书", "files", strings.Join(c.files(), ","))
+}
+
+// clientConfig 返回连接addr使用的TLS配置
+func (c *tlsCredentials) clientConfig(addr string) *tls.Config {
+	c.reload()
+
+	serverName := c.config.ServerName
+	if serverName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			serverName = host
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		RootCAs:            c.roots,
+		MinVersion:         c.config.MinVersion,
+		InsecureSkipVerify: c.config.InsecureSkipVerify,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	if c.cert != nil {
+		cfg.Certificates = []tls.Certificate{*c.cert}
+	}
+	return cfg
+}
+
+// dial 建立TCP连接并完成TLS握手
+func (c *tlsCredentials) dial(ctx context.Context, dialer *net.Dialer, addr string) (net.Conn, error) {
+	if dialer.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dialer.Timeout)
+		defer cancel()
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, c.clientConfig(addr))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "tls", Net: "tcp", Addr: conn.RemoteAddr(), Err: tlsError(err)}
+	}
+	return tlsConn, nil
+}
+
+// transportConfig 返回HTTP接口使用的TLS配置。HTTP传输层的所有连接共用这个配置，
+// 因此由VerifyConnection按当前的CA校验服务端证书，由GetClientCertificate返回当前的客户端证书，
+// 证书文件变化后新连接同样使用重新加载的证书。连接IP地址时没有SNI，VerifyConnection
+// 无法得知连接的是哪个地址，因此addrs中有IP地址时必须配置ServerName
+func (c *tlsCredentials) transportConfig(addrs []string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: c.config.ServerName,
+		MinVersion: cmp.Or(c.config.MinVersion, tls.VersionTLS12),
+		// 证书由VerifyConnection校验
+		InsecureSkipVerify: true,
+	}
+	if !c.config.InsecureSkipVerify {
+		if c.config.ServerName == "" {
+			for _, addr := range addrs {
+				if host, _, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) != nil {
+					return nil, fmt.Errorf("通过HTTPS连接IP地址%s时需要配置服务端名称，用于校验服务端证书", host)
+				}
+			}
+		}
+		cfg.VerifyConnection = c.verify
+	}
+	if c.config.CertFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c.reload()
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.cert, nil
+		}
+	}
+	return cfg, nil
+}
+
+// verify 按当前的CA校验服务端证书链和主机名，主机名为配置的ServerName或SNI
+func (c *tlsCredentials) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("服务端没有提供证书")
+	}
+	c.reload()
+	c.mu.Lock()
+	roots := c.roots
+	c.mu.Unlock()
+
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	leaf := cs.PeerCertificates[0]
+	if _, err := leaf.Verify(opts); err != nil {
+		return tlsError(err)
+	}
+
+	name := cmp.Or(c.config.ServerName, cs.ServerName)
+	if name == "" {
+		return fmt.Errorf("没有用于校验服务端证书的主机名")
+	}
+	return tlsError(leaf.VerifyHostname(name))
+}
+
+// tlsError 为证书校验失败补充说明
+func tlsError(err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("%w(请通过CA证书配置指定签发服务端证书的CA)", err)
+	case errors.As(err, &hostname):
+		return fmt.Errorf("%w(请检查连接地址或配置服务端名称)", err)
+	}
+	return err
+}
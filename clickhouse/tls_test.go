@@ -0,0 +1,371 @@
+package clickhouse
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert 测试用的证书和私钥
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert 创建由parent签发的证书，parent为nil时创建自签名的CA证书
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"clickhouse.local"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// tlsCertificate 返回可用于tls.Config的证书
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// writePEM 将证书和私钥写入dir中的name.crt和name.key，返回文件路径
+func (c *testCert) writePEM(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certFile, keyFile
+}
+
+// writeFile 写入文件并把修改时间设为当前时间之后，保证重新加载能发现变化
+func writeFile(t *testing.T, file string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Duration(len(data)) * time.Second)
+	if err := os.Chtimes(file, future, future); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// startTLSServer 启动要求客户端证书的TLS服务端，返回地址和收到的客户端证书名称
+func startTLSServer(t *testing.T, ca, server *testCert) (string, <-chan string) {
+	t.Helper()
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	clients := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			if err := tlsConn.Handshake(); err == nil {
+				clients <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String(), clients
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    uint16
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"TLS1.3", tls.VersionTLS13, false},
+		{" tls 1.0 ", tls.VersionTLS10, false},
+		{"1.4", 0, true},
+		{"ssl3", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTLSVersion(%q) = %v, %v, ожидалось %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewTLSCredentialsErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	notPEM := filepath.Join(dir, "not.pem")
+	writeFile(t, notPEM, []byte("not a certificate"))
+
+	tests := []struct {
+		name    string
+		config  TLSConfig
+		wantErr string
+	}{
+		{"Сертификат без ключа", TLSConfig{CertFile: caFile}, "同时配置"},
+		{"CA вместе с отключённой проверкой", TLSConfig{CAFile: caFile, InsecureSkipVerify: true}, "不能同时配置"},
+		{"Нет файла CA", TLSConfig{CAFile: filepath.Join(dir, "missing.crt")}, "missing.crt"},
+		{"CA не в формате PEM", TLSConfig{CAFile: notPEM}, "没有PEM格式的证书"},
+		{"Некорректный клиентский сертификат", TLSConfig{CertFile: notPEM, KeyFile: notPEM}, "加载客户端证书失败"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTLSCredentials(tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("newTLSCredentials() error = %v, ожидалось %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSCredentialsDial(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	addr, clients := startTLSServer(t, ca, newTestCert(t, "server", ca))
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "client-1", ca).writePEM(t, dir, "client")
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+
+	dial := func(t *testing.T, creds *tlsCredentials) error {
+		t.Helper()
+		conn, err := creds.dial(context.Background(), dialer, addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	expectClient := func(t *testing.T, want string) {
+		t.Helper()
+		select {
+		case got := <-clients:
+			if got != want {
+				t.Errorf("клиентский сертификат %q, ожидался %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("сервер не получил клиентский сертификат %q", want)
+		}
+	}
+
+	creds, err := newTLSCredentials(TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS13})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("mTLS с проверкой сертификата сервера", func(t *testing.T) {
+		if err := dial(t, creds); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-1")
+	})
+
+	t.Run("Новые сертификаты загружаются после изменения файлов", func(t *testing.T) {
+		newTestCert(t, "client-2", ca).writePEM(t, dir, "client")
+		if err := dial(t, creds); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-2")
+	})
+
+	t.Run("Некорректный файл не заменяет загруженные сертификаты", func(t *testing.T) {
+		writeFile(t, caFile, []byte("broken"))
+		if err := dial(t, creds); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-2")
+	})
+
+	t.Run("Неизвестный CA", func(t *testing.T) {
+		other, err := newTLSCredentials(TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dial(t, other); err == nil || !strings.Contains(err.Error(), "CA") {
+			t.Errorf("dial() error = %v, ожидалась ошибка проверки сертификата", err)
+		}
+	})
+
+	t.Run("Имя сервера не совпадает с сертификатом", func(t *testing.T) {
+		caFile, _ := ca.writePEM(t, t.TempDir(), "ca")
+		other, err := newTLSCredentials(TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "other.local"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dial(t, other); err == nil || !strings.Contains(err.Error(), "other.local") {
+			t.Errorf("dial() error = %v, ожидалась ошибка имени сервера", err)
+		}
+	})
+
+	t.Run("Имя сервера из конфигурации", func(t *testing.T) {
+		caFile, _ := ca.writePEM(t, t.TempDir(), "ca")
+		other, err := newTLSCredentials(TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "clickhouse.local"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dial(t, other); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-2")
+	})
+
+	t.Run("Без проверки сертификата", func(t *testing.T) {
+		other, err := newTLSCredentials(TLSConfig{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dial(t, other); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-2")
+	})
+}
+
+func TestTLSCredentialsTransportConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "ca", nil)
+	addr, clients := startTLSServer(t, ca, newTestCert(t, "server", ca))
+	caFile, _ := ca.writePEM(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, "client-1", ca).writePEM(t, dir, "client")
+
+	// dial 连接服务端，与HTTP传输层相同，未配置ServerName时使用地址中的主机
+	dial := func(t *testing.T, cfg *tls.Config) error {
+		t.Helper()
+		cfg = cfg.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 5 * time.Second}, "tcp", addr, cfg)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+	expectClient := func(t *testing.T, want string) {
+		t.Helper()
+		select {
+		case got := <-clients:
+			if got != want {
+				t.Errorf("клиентский сертификат %q, ожидался %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("сервер не получил клиентский сертификат %q", want)
+		}
+	}
+
+	// transportConfig создаёт конфигурацию HTTP-транспорта для адреса addr
+	transportConfig := func(t *testing.T, cfg TLSConfig) *tls.Config {
+		t.Helper()
+		creds, err := newTLSCredentials(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport, err := creds.transportConfig([]string{addr})
+		if err != nil {
+			t.Fatalf("transportConfig() error = %v", err)
+		}
+		return transport
+	}
+	cfg := transportConfig(t, TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "clickhouse.local"})
+
+	t.Run("Имя сервера из конфигурации", func(t *testing.T) {
+		if err := dial(t, cfg); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-1")
+	})
+
+	t.Run("Новые сертификаты загружаются после изменения файлов", func(t *testing.T) {
+		newTestCert(t, "client-2", ca).writePEM(t, dir, "client")
+		if err := dial(t, cfg); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-2")
+	})
+
+	t.Run("IP-адрес в имени сервера", func(t *testing.T) {
+		host, _, _ := net.SplitHostPort(addr)
+		if err := dial(t, transportConfig(t, TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: host})); err != nil {
+			t.Fatalf("dial() error = %v", err)
+		}
+		expectClient(t, "client-2")
+	})
+
+	t.Run("IP-адрес без имени сервера", func(t *testing.T) {
+		creds, err := newTLSCredentials(TLSConfig{CAFile: caFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Иначе подошёл бы сертификат, выданный любому другому адресу из списка
+		if _, err := creds.transportConfig([]string{"clickhouse.local:8443", addr}); err == nil || !strings.Contains(err.Error(), "服务端名称") {
+			t.Errorf("transportConfig() error = %v, ожидалась ошибка об имени сервера", err)
+		}
+	})
+
+	t.Run("Сертификат не выдан для имени сервера", func(t *testing.T) {
+		other := transportConfig(t, TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "other.local"})
+		if err := dial(t, other); err == nil || !strings.Contains(err.Error(), "other.local") {
+			t.Errorf("dial() error = %v, ожидалась ошибка имени сервера", err)
+		}
+	})
+
+	t.Run("Неизвестный CA", func(t *testing.T) {
+		other := transportConfig(t, TLSConfig{CertFile: certFile, KeyFile: keyFile, ServerName: "clickhouse.local"})
+		if err := dial(t, other); err == nil || !strings.Contains(err.Error(), "CA") {
+			t.Errorf("dial() error = %v, ожидалась ошибка проверки сертификата", err)
+		}
+	})
+}
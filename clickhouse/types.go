@@ -0,0 +1,323 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"clickhouse-mcp/lexer"
+)
+
+// Type 表示解析后的ClickHouse数据类型
+type Type struct {
+	// Name 类型名，如 "UInt64"、"Nullable"、"DateTime64"
+	Name string
+	// Params 字面量参数，如 DateTime64(3, 'UTC') 中的 "3" 和 "UTC"，引号已去除
+	Params []string
+	// Elems 类型参数，如 Array(T)、Map(K, V)、Tuple(...) 中的元素类型
+	Elems []*Type
+	// Fields Tuple/Nested 的字段名，与Elems一一对应，未命名元素为空串
+	Fields []string
+}
+
+// typeArgKind 描述类型参数的解析方式
+type typeArgKind int
+
+const (
+	// literalArgs 所有参数都是字面量
+	literalArgs typeArgKind = iota
+	// typeArgs 所有参数都是类型，可带字段名
+	typeArgs
+	// functionArgs 第一个参数是聚合函数名，其余是类型
+	functionArgs
+)
+
+// argKinds 以类型为参数的复合类型
+var argKinds = map[string]typeArgKind{
+	"Nullable":                typeArgs,
+	"LowCardinality":          typeArgs,
+	"Array":                   typeArgs,
+	"Map":                     typeArgs,
+	"Tuple":                   typeArgs,
+	"Nested":                  typeArgs,
+	"Variant":                 typeArgs,
+	"SimpleAggregateFunction": functionArgs,
+	"AggregateFunction":       functionArgs,
+}
+
+// ParseType 解析ClickHouse类型字符串，如 "LowCardinality(Nullable(String))"
+func ParseType(s string) (*Type, error) {
+	p := &typeParser{src: s, tokens: lexer.Significant(lexer.Tokenize(s))}
+	t, err := p.parseType()
+	if err != nil {
+		return nil, fmt.Errorf("解析类型%q失败: %w", s, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("解析类型%q失败: 位置%d存在多余内容", s, p.tokens[p.pos].Pos)
+	}
+	return t, nil
+}
+
+// String 返回类型的规范文本表示
+func (t *Type) String() string {
+	if len(t.Params) == 0 && len(t.Elems) == 0 {
+		return t.Name
+	}
+
+	var args []string
+	switch argKinds[t.Name] {
+	case literalArgs:
+		for _, p := range t.Params {
+			args = append(args, formatTypeParam(t.Name, p))
+		}
+	case functionArgs:
+		args = append(args, t.Params...)
+		fallthrough
+	case typeArgs:
+		for i, elem := range t.Elems {
+			if i < len(t.Fields) && t.Fields[i] != "" {
+				args = append(args, t.Fields[i]+" "+elem.String())
+			} else {
+				args = append(args, elem.String())
+			}
+		}
+	}
+	return t.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// formatTypeParam 还原字面量参数的引号
+func formatTypeParam(typeName, param string) string {
+	switch typeName {
+	case "DateTime", "DateTime64", "Object":
+		if !isNumeric(param) {
+			return "'" + strings.ReplaceAll(param, "'", "\\'") + "'"
+		}
+	}
+	return param
+}
+
+// Unwrap 去除Nullable、LowCardinality和SimpleAggregateFunction包装，返回实际存储的类型
+func (t *Type) Unwrap() *Type {
+	for {
+		switch {
+		case (t.Name == "Nullable" || t.Name == "LowCardinality") && len(t.Elems) == 1:
+			t = t.Elems[0]
+		case t.Name == "SimpleAggregateFunction" && len(t.Elems) == 1:
+			t = t.Elems[0]
+		default:
+			return t
+		}
+	}
+}
+
+// IsNullable 类型是否可为NULL(包括 LowCardinality(Nullable(T)))
+func (t *Type) IsNullable() bool {
+	for {
+		switch {
+		case t.Name == "Nullable":
+			return true
+		case t.Name == "LowCardinality" && len(t.Elems) == 1:
+			t = t.Elems[0]
+		default:
+			return false
+		}
+	}
+}
+
+// Param 返回第i个字面量参数，不存在时返回空串
+func (t *Type) Param(i int) string {
+	if i < len(t.Params) {
+		return t.Params[i]
+	}
+	return ""
+}
+
+// Timezone 返回DateTime('tz')或DateTime64(p, 'tz')中声明的时区，未声明时返回空串
+func (t *Type) Timezone() string {
+	switch t.Name {
+	case "DateTime":
+		return t.Param(0)
+	case "DateTime64":
+		return t.Param(1)
+	}
+	return ""
+}
+
+// Precision 返回DateTime64的小数位数，其他类型返回0
+func (t *Type) Precision() int {
+	if t.Name != "DateTime64" {
+		return 0
+	}
+	precision, err := strconv.Atoi(t.Param(0))
+	if err != nil || precision < 0 {
+		return 0
+	}
+	return min(precision, 9)
+}
+
+// find 深度优先查找第一个满足条件的类型节点
+func (t *Type) find(match func(*Type) bool) *Type {
+	if match(t) {
+		return t
+	}
+	for _, elem := range t.Elems {
+		if found := elem.find(match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// typeParser 基于词法单元的类型解析器
+type typeParser struct {
+	src    string
+	tokens []lexer.Token
+	pos    int
+}
+
+func (p *typeParser) peek(offset int) (lexer.Token, bool) {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset], true
+	}
+	return lexer.Token{}, false
+}
+
+// parseType 解析 Name 或 Name(args)
+func (p *typeParser) parseType() (*Type, error) {
+	tok, ok := p.peek(0)
+	if !ok {
+		return nil, fmt.Errorf("缺少类型名")
+	}
+	if tok.Type != lexer.Word {
+		return nil, fmt.Errorf("位置%d应为类型名，实际为%q", tok.Pos, tok.Text)
+	}
+	p.pos++
+
+	t := &Type{Name: tok.Text}
+	if next, ok := p.peek(0); !ok || !next.IsPunct("(") {
+		return t, nil
+	}
+	p.pos++
+
+	if next, ok := p.peek(0); ok && next.IsPunct(")") {
+		p.pos++
+		return t, nil
+	}
+
+	for i := 0; ; i++ {
+		var err error
+		kind := argKinds[t.Name]
+		if kind == literalArgs || (kind == functionArgs && i == 0) {
+			err = p.parseLiteralArg(t)
+		} else {
+			err = p.parseTypeArg(t)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		next, ok := p.peek(0)
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("类型%s缺少右括号", t.Name)
+		case next.IsPunct(","):
+			p.pos++
+		case next.IsPunct(")"):
+			p.pos++
+			return t, nil
+		default:
+			return nil, fmt.Errorf("位置%d存在意外的%q", next.Pos, next.Text)
+		}
+	}
+}
+
+// parseTypeArg 解析类型参数，支持 "name Type" 形式的命名元素
+func (p *typeParser) parseTypeArg(t *Type) error {
+	field := ""
+	if tok, ok := p.peek(0); ok && (tok.Type == lexer.Word || tok.Type == lexer.QuotedIdentifier) {
+		if next, ok := p.peek(1); ok && (next.Type == lexer.Word || next.Type == lexer.QuotedIdentifier) {
+			field = unquote(tok)
+			p.pos++
+		}
+	}
+
+	elem, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	t.Elems = append(t.Elems, elem)
+	if field != "" || len(t.Fields) > 0 {
+		for len(t.Fields) < len(t.Elems)-1 {
+			t.Fields = append(t.Fields, "")
+		}
+		t.Fields = append(t.Fields, field)
+	}
+	return nil
+}
+
+// parseLiteralArg 解析到下一个顶层逗号或右括号为止的字面量参数
+func (p *typeParser) parseLiteralArg(t *Type) error {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		if depth == 0 && (tok.IsPunct(",") || tok.IsPunct(")")) {
+			break
+		}
+		switch {
+		case isOpenBracket(tok):
+			depth++
+		case isCloseBracket(tok):
+			depth--
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return fmt.Errorf("类型%s存在空参数", t.Name)
+	}
+
+	if p.pos-start == 1 {
+		t.Params = append(t.Params, unquote(p.tokens[start]))
+	} else {
+		t.Params = append(t.Params, p.src[p.tokens[start].Pos:p.tokens[p.pos-1].End()])
+	}
+	return nil
+}
+
+// unquote 去除字符串或标识符的引号并处理转义
+func unquote(tok lexer.Token) string {
+	if (tok.Type != lexer.String && tok.Type != lexer.QuotedIdentifier) || tok.Unterminated || len(tok.Text) < 2 {
+		return tok.Text
+	}
+	quote := tok.Text[0]
+	body := tok.Text[1 : len(tok.Text)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body):
+			i++
+			b.WriteByte(body[i])
+		case c == quote && i+1 < len(body) && body[i+1] == quote:
+			i++
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isNumeric 是否为纯数字
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+package clickhouse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    *Type
+		wantErr bool
+	}{
+		{name: "Простой тип", input: "UInt64", want: &Type{Name: "UInt64"}},
+		{
+			name:  "Nullable",
+			input: "Nullable(String)",
+			want:  &Type{Name: "Nullable", Elems: []*Type{{Name: "String"}}},
+		},
+		{
+			name:  "LowCardinality(Nullable)",
+			input: "LowCardinality(Nullable(String))",
+			want: &Type{Name: "LowCardinality", Elems: []*Type{
+				{Name: "Nullable", Elems: []*Type{{Name: "String"}}},
+			}},
+		},
+		{
+			name:  "Decimal",
+			input: "Decimal(18, 4)",
+			want:  &Type{Name: "Decimal", Params: []string{"18", "4"}},
+		},
+		{
+			name:  "DateTime64 с часовым поясом",
+			input: "DateTime64(3, 'Asia/Shanghai')",
+			want:  &Type{Name: "DateTime64", Params: []string{"3", "Asia/Shanghai"}},
+		},
+		{
+			name:  "Enum8",
+			input: "Enum8('a' = 1, 'b,c' = 2)",
+			want:  &Type{Name: "Enum8", Params: []string{"'a' = 1", "'b,c' = 2"}},
+		},
+		{
+			name:  "Map",
+			input: "Map(String, Array(UInt8))",
+			want: &Type{Name: "Map", Elems: []*Type{
+				{Name: "String"},
+				{Name: "Array", Elems: []*Type{{Name: "UInt8"}}},
+			}},
+		},
+		{
+			name:  "Именованный Tuple",
+			input: "Tuple(id UInt64, `user name` String)",
+			want: &Type{
+				Name:   "Tuple",
+				Elems:  []*Type{{Name: "UInt64"}, {Name: "String"}},
+				Fields: []string{"id", "user name"},
+			},
+		},
+		{
+			name:  "Безымянный Tuple",
+			input: "Tuple(UInt8, String)",
+			want:  &Type{Name: "Tuple", Elems: []*Type{{Name: "UInt8"}, {Name: "String"}}},
+		},
+		{
+			name:  "Nested",
+			input: "Nested(a Int32, b Nullable(String))",
+			want: &Type{
+				Name: "Nested",
+				Elems: []*Type{
+					{Name: "Int32"},
+					{Name: "Nullable", Elems: []*Type{{Name: "String"}}},
+				},
+				Fields: []string{"a", "b"},
+			},
+		},
+		{
+			name:  "AggregateFunction",
+			input: "AggregateFunction(quantiles(0.5, 0.9), UInt64)",
+			want: &Type{
+				Name:   "AggregateFunction",
+				Params: []string{"quantiles(0.5, 0.9)"},
+				Elems:  []*Type{{Name: "UInt64"}},
+			},
+		},
+		{
+			name:  "SimpleAggregateFunction",
+			input: "SimpleAggregateFunction(sum, Double)",
+			want: &Type{
+				Name:   "SimpleAggregateFunction",
+				Params: []string{"sum"},
+				Elems:  []*Type{{Name: "Double"}},
+			},
+		},
+		{name: "Пустая строка", input: "", wantErr: true},
+		{name: "Незакрытая скобка", input: "Array(String", wantErr: true},
+		{name: "Лишний текст", input: "String String", wantErr: true},
+		{name: "Пустой параметр", input: "Decimal(10, )", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseType(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseType() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	tests := []string{
+		"UInt64",
+		"Nullable(String)",
+		"LowCardinality(Nullable(String))",
+		"Decimal(18, 4)",
+		"DateTime64(3, 'Asia/Shanghai')",
+		"Enum8('a' = 1, 'b' = 2)",
+		"Map(String, Array(UInt8))",
+		"Tuple(id UInt64, name String)",
+		"Nested(a Int32, b Nullable(String))",
+		"AggregateFunction(uniq, String)",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			typ, err := ParseType(input)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			if got := typ.String(); got != input {
+				t.Errorf("String() = %q, want %q", got, input)
+			}
+		})
+	}
+}
+
+func TestTypeUnwrap(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		want         string
+		wantNullable bool
+	}{
+		{name: "Простой тип", input: "String", want: "String"},
+		{name: "Nullable", input: "Nullable(Int32)", want: "Int32", wantNullable: true},
+		{name: "LowCardinality(Nullable)", input: "LowCardinality(Nullable(String))", want: "String", wantNullable: true},
+		{name: "SimpleAggregateFunction", input: "SimpleAggregateFunction(max, UInt8)", want: "UInt8"},
+		{name: "Массив Nullable", input: "Array(Nullable(String))", want: "Array(Nullable(String))"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, err := ParseType(tt.input)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			if got := typ.Unwrap().String(); got != tt.want {
+				t.Errorf("Unwrap() = %q, want %q", got, tt.want)
+			}
+			if got := typ.IsNullable(); got != tt.wantNullable {
+				t.Errorf("IsNullable() = %v, want %v", got, tt.wantNullable)
+			}
+		})
+	}
+}
+
+func TestTypeDateTime(t *testing.T) {
+	tests := []struct {
+		input         string
+		wantTimezone  string
+		wantPrecision int
+	}{
+		{input: "DateTime"},
+		{input: "DateTime('UTC')", wantTimezone: "UTC"},
+		{input: "DateTime64(3)", wantPrecision: 3},
+		{input: "DateTime64(6, 'Asia/Shanghai')", wantTimezone: "Asia/Shanghai", wantPrecision: 6},
+		{input: "Date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			typ, err := ParseType(tt.input)
+			if err != nil {
+				t.Fatalf("ParseType() error = %v", err)
+			}
+			if got := typ.Timezone(); got != tt.wantTimezone {
+				t.Errorf("Timezone() = %q, want %q", got, tt.wantTimezone)
+			}
+			if got := typ.Precision(); got != tt.wantPrecision {
+				t.Errorf("Precision() = %d, want %d", got, tt.wantPrecision)
+			}
+		})
+	}
+}
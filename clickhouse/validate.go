@@ -0,0 +1,159 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+const (
+	// codeUnknownFunction 服务端不支持formatQuery时返回的错误码(早于23.11的版本)
+	codeUnknownFunction = 46
+	// codeUnknownQueryParameter EXPLAIN AST的查询含有未提供值的参数占位符，此时查询已成功解析
+	codeUnknownQueryParameter = 456
+)
+
+// isParserError 是否为解析查询时出现的错误: SYNTAX_ERROR、TOO_DEEP_AST、TOO_BIG_AST或TOO_DEEP_RECURSION
+func isParserError(code int32) bool {
+	switch code {
+	case 62, 167, 168, 306:
+		return true
+	}
+	return false
+}
+
+// SyntaxError 查询的语法错误。Line和Column为错误在原始查询中的位置，从1开始，
+// Column按字符计数，服务端未报告位置时为0
+type SyntaxError struct {
+	// Code ClickHouse错误码
+	Code int32 `json:"code,omitempty"`
+	// Name 错误码名称，如SYNTAX_ERROR
+	Name string `json:"name,omitempty"`
+	// Message 服务端返回的错误信息
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+
+	// position 出错位置相对于语句的字节偏移，从1开始，0表示未知
+	position int
+}
+
+// Error 返回包含位置和错误码的错误信息
+func (e *SyntaxError) Error() string {
+	var b strings.Builder
+	b.WriteString("语法错误")
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "(第%d行第%d列)", e.Line, e.Column)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Message)
+	if e.Code != 0 {
+		fmt.Fprintf(&b, " (code %d", e.Code)
+		if e.Name != "" {
+			b.WriteString(", " + e.Name)
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// failedPosition 解析器错误信息中的出错位置，为被解析文本中从1开始的字节偏移
+var failedPosition = regexp.MustCompile(`failed at position (\d+)`)
+
+// ValidateQuery 由服务端解析查询但不执行，返回格式化后的查询，多条语句以分号分隔。
+// 查询无法解析时返回*SyntaxError。服务端支持时使用formatQuery()，否则使用EXPLAIN AST，
+// 此时返回规范化后的原始文本
+func (c *DefaultClient) ValidateQuery(ctx context.Context, query string) (string, error) {
+	statements := splitStatements(query)
+	if len(statements) == 0 {
+		return "", &SyntaxError{Message: "查询为空"}
+	}
+
+	if _, err := c.ensureConnection(ctx); err != nil {
+		return "", fmt.Errorf("连接错误: %w", err)
+	}
+
+	formatted := make([]string, len(statements))
+	for i, stmt := range statements {
+		text, err := c.formatStatement(ctx, stmt.text)
+		if err != nil {
+			var syntaxErr *SyntaxError
+			if errors.As(err, &syntaxErr) {
+				syntaxErr.locate(query, stmt.tokens[0].Pos)
+			}
+			return "", err
+		}
+		formatted[i] = text
+	}
+	return strings.Join(formatted, ";\n\n"), nil
+}
+
+// formatQueryParams formatQuery()的参数，语句按Escaped格式转义，由withParameters按协议编码
+func formatQueryParams(statement string) clickhouse.Parameters {
+	return textParams{"query": statement}.escaped()
+}
+
+// formatStatement 用formatQuery()解析并格式化单条语句，服务端没有该函数时改用EXPLAIN AST
+func (c *DefaultClient) formatStatement(ctx context.Context, statement string) (string, error) {
+	var formatted string
+	err := c.queryRow(c.withParameters(ctx, formatQueryParams(statement)), "SELECT formatQuery({query:String})", &formatted)
+
+	// offset 被解析文本中语句之前的字节数
+	offset := 0
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) && exception.Code == codeUnknownFunction {
+		const prefix = "EXPLAIN AST "
+		formatted, offset = statement, len(prefix)
+		err = c.explainAST(ctx, prefix+statement)
+		if errors.As(err, &exception) && exception.Code == codeUnknownQueryParameter {
+			err = nil
+		}
+	}
+
+	if errors.As(err, &exception) {
+		if isParserError(exception.Code) {
+			return "", syntaxError(exception, offset)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("解析查询失败: %w", serverError(err))
+	}
+	return formatted, nil
+}
+
+// explainAST 执行EXPLAIN AST，只关心查询能否被解析
+func (c *DefaultClient) explainAST(ctx context.Context, statement string) error {
+	rows, err := c.query(ctx, statement)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// syntaxError 将服务端异常转换为SyntaxError，offset为被解析文本中语句之前的字节数
+func syntaxError(e *clickhouse.Exception, offset int) *SyntaxError {
+	result := &SyntaxError{Code: e.Code, Name: errorName(e.Code, e.Message), Message: e.Message}
+	if m := failedPosition.FindStringSubmatch(e.Message); m != nil {
+		if pos, err := strconv.Atoi(m[1]); err == nil && pos > offset {
+			result.position = pos - offset
+		}
+	}
+	return result
+}
+
+// locate 将出错位置换算为原始查询中的行和列，start为语句在查询中的起始字节
+func (e *SyntaxError) locate(query string, start int) {
+	if e.position == 0 {
+		return
+	}
+	pos := min(start+e.position-1, len(query))
+	before := query[:pos]
+	e.Line = strings.Count(before, "\n") + 1
+	e.Column = utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+}
@@ -0,0 +1,165 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// formattedRows - ответ formatQuery()
+func formattedRows(query string) *fakeRows {
+	return &fakeRows{
+		columns: []fakeColumnType{{name: "formatQuery", typ: "String", scan: reflect.TypeOf("")}},
+		data:    [][]any{{query}},
+	}
+}
+
+// exceptionRows - ответ с ошибкой сервера
+func exceptionRows(code int32, message string) *fakeRows {
+	return &fakeRows{err: &clickhouse.Exception{Code: code, Name: "DB::Exception", Message: message}}
+}
+
+func TestValidateQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		responses []*fakeRows
+		want      string
+		wantErr   *SyntaxError
+		queries   []string
+	}{
+		{
+			name:      "Корректный запрос",
+			query:     "select 1;",
+			responses: []*fakeRows{formattedRows("SELECT 1")},
+			want:      "SELECT 1",
+			queries:   []string{"SELECT formatQuery({query:String})"},
+		},
+		{
+			name:      "Несколько выражений",
+			query:     "select 1; select 2",
+			responses: []*fakeRows{formattedRows("SELECT 1"), formattedRows("SELECT 2")},
+			want:      "SELECT 1;\n\nSELECT 2",
+		},
+		{
+			name:  "Синтаксическая ошибка во втором выражении",
+			query: "SELECT 1;\n-- комментарий\nSELECT\n  ключ FORM t",
+			responses: []*fakeRows{
+				formattedRows("SELECT 1"),
+				exceptionRows(62, "Syntax error: failed at position 19 ('FORM'): FORM t. Expected one of: token, Comma, FROM"),
+			},
+			wantErr: &SyntaxError{
+				Code:    62,
+				Name:    "SYNTAX_ERROR",
+				Message: "Syntax error: failed at position 19 ('FORM'): FORM t. Expected one of: token, Comma, FROM",
+				Line:    4,
+				Column:  8,
+			},
+		},
+		{
+			name:  "Старый сервер без formatQuery",
+			query: "SELECT {id:UInt64}",
+			responses: []*fakeRows{
+				exceptionRows(46, "Unknown function formatQuery"),
+				exceptionRows(456, "Substitution `id` is not set"),
+			},
+			want:    "SELECT {id:UInt64}",
+			queries: []string{"SELECT formatQuery({query:String})", "EXPLAIN AST SELECT {id:UInt64}"},
+		},
+		{
+			name:  "Ошибка в EXPLAIN AST",
+			query: "SELECT 1 +",
+			responses: []*fakeRows{
+				exceptionRows(46, "Unknown function formatQuery"),
+				exceptionRows(62, "Syntax error: failed at position 23 (end of query): . Expected one of: expression"),
+			},
+			wantErr: &SyntaxError{
+				Code:    62,
+				Name:    "SYNTAX_ERROR",
+				Message: "Syntax error: failed at position 23 (end of query): . Expected one of: expression",
+				Line:    1,
+				Column:  11,
+			},
+		},
+		{
+			name:    "Пустой запрос",
+			query:   " -- только комментарий\n;",
+			wantErr: &SyntaxError{Message: "查询为空"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &fakeConn{responses: tt.responses}
+			client := &DefaultClient{conn: conn}
+
+			got, err := client.ValidateQuery(context.Background(), tt.query)
+			if tt.wantErr != nil {
+				var syntaxErr *SyntaxError
+				if !errors.As(err, &syntaxErr) {
+					t.Fatalf("ValidateQuery() error = %v, ожидалась SyntaxError", err)
+				}
+				syntaxErr.position = 0
+				if !reflect.DeepEqual(syntaxErr, tt.wantErr) {
+					t.Errorf("ValidateQuery() error = %+v, ожидалось %+v", syntaxErr, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateQuery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateQuery() = %q, ожидалось %q", got, tt.want)
+			}
+			if tt.queries != nil && !reflect.DeepEqual(conn.queries, tt.queries) {
+				t.Errorf("ValidateQuery() запросы = %q, ожидалось %q", conn.queries, tt.queries)
+			}
+		})
+	}
+
+	t.Run("Прочие ошибки сервера не считаются синтаксическими", func(t *testing.T) {
+		client := &DefaultClient{conn: &fakeConn{responses: []*fakeRows{exceptionRows(241, "Memory limit exceeded")}}}
+		_, err := client.ValidateQuery(context.Background(), "SELECT 1")
+		var syntaxErr *SyntaxError
+		if err == nil || errors.As(err, &syntaxErr) || !strings.Contains(err.Error(), "解析查询失败") {
+			t.Errorf("ValidateQuery() error = %v", err)
+		}
+	})
+}
+
+func TestSyntaxErrorMessage(t *testing.T) {
+	err := &SyntaxError{Code: 62, Name: "SYNTAX_ERROR", Message: "failed at position 8", Line: 2, Column: 3}
+	want := "语法错误(第2行第3列): failed at position 8 (code 62, SYNTAX_ERROR)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, ожидалось %q", err.Error(), want)
+	}
+}
+
+func TestFormatQueryParams(t *testing.T) {
+	statement := "SELECT 'a\\b'\nFROM t\tWHERE x = 1"
+	tests := []struct {
+		protocol Protocol
+		want     string
+	}{
+		{protocol: ProtocolNative, want: `SELECT 'a\\\\b'\\nFROM t\\tWHERE x = 1`},
+		{protocol: ProtocolHTTP, want: `SELECT 'a\\b'\nFROM t\tWHERE x = 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.protocol), func(t *testing.T) {
+			got := encodeParams(formatQueryParams(statement), tt.protocol)
+			if !reflect.DeepEqual(got, clickhouse.Parameters{"query": tt.want}) {
+				t.Errorf("параметры formatQuery() = %q, ожидалось %q", got["query"], tt.want)
+			}
+			// Сервер снимает экранирование и получает исходное выражение
+			text := serverText(got["query"], tt.protocol)
+			if unescaped := strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(text); unescaped != statement {
+				t.Errorf("сервер получит %q, ожидалось %q", unescaped, statement)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package lexer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TokenType 表示词法单元类型
+type TokenType int
+
+const (
+	// Whitespace 空白字符
+	Whitespace TokenType = iota
+	// LineComment 单行注释: "-- ..." 或 "# ..."
+	LineComment
+	// BlockComment 块注释: "/* ... */"，支持嵌套
+	BlockComment
+	// String 单引号字符串字面量
+	String
+	// QuotedIdentifier 双引号或反引号包围的标识符
+	QuotedIdentifier
+	// Heredoc 字符串字面量: $tag$ ... $tag$
+	Heredoc
+	// Number 数字字面量
+	Number
+	// Word 关键字或未加引号的标识符
+	Word
+	// Punct 运算符和标点
+	Punct
+)
+
+// String 返回词法单元类型名称
+func (t TokenType) String() string {
+	switch t {
+	case Whitespace:
+		return "whitespace"
+	case LineComment:
+		return "line_comment"
+	case BlockComment:
+		return "block_comment"
+	case String:
+		return "string"
+	case QuotedIdentifier:
+		return "quoted_identifier"
+	case Heredoc:
+		return "heredoc"
+	case Number:
+		return "number"
+	case Word:
+		return "word"
+	case Punct:
+		return "punct"
+	default:
+		return "unknown"
+	}
+}
+
+// Token 表示一个词法单元
+type Token struct {
+	Type TokenType
+	// Text 原始文本，所有词法单元的Text依次拼接等于输入
+	Text string
+	// Pos 在输入中的字节偏移
+	Pos int
+	// Unterminated 字符串、标识符、注释或heredoc未闭合
+	Unterminated bool
+}
+
+// IsComment 是否为注释
+func (t Token) IsComment() bool {
+	return t.Type == LineComment || t.Type == BlockComment
+}
+
+// Significant 是否为有意义的词法单元(非空白、非注释)
+func (t Token) Significant() bool {
+	return t.Type != Whitespace && !t.IsComment()
+}
+
+// IsKeyword 是否为指定关键字(不区分大小写)
+func (t Token) IsKeyword(keyword string) bool {
+	return t.Type == Word && strings.EqualFold(t.Text, keyword)
+}
+
+// IsPunct 是否为指定标点
+func (t Token) IsPunct(punct string) bool {
+	return t.Type == Punct && t.Text == punct
+}
+
+// End 返回词法单元结束位置
+func (t Token) End() int {
+	return t.Pos + len(t.Text)
+}
+
+// Lexer ClickHouse SQL词法分析器
+type Lexer struct {
+	src string
+	pos int
+}
+
+// New 创建词法分析器
+func New(src string) *Lexer {
+	return &Lexer{src: src}
+}
+
+// Tokenize 将SQL文本切分为词法单元
+func Tokenize(src string) []Token {
+	l := New(src)
+	var tokens []Token
+	for {
+		tok, ok := l.Next()
+		if !ok {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+// Significant 过滤掉空白和注释
+func Significant(tokens []Token) []Token {
+	result := make([]Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Significant() {
+			result = append(result, tok)
+		}
+	}
+	return result
+}
+
+// multiCharPuncts 多字符运算符，按长度降序匹配
+var multiCharPuncts = []string{"<=>", "::", "->", "||", "<=", ">=", "!=", "<>", "=="}
+
+// Next 返回下一个词法单元，输入结束时返回false
+func (l *Lexer) Next() (Token, bool) {
+	if l.pos >= len(l.src) {
+		return Token{}, false
+	}
+
+	start := l.pos
+	rest := l.src[start:]
+	c := rest[0]
+	tok := Token{Pos: start}
+
+	switch {
+	case isSpace(c):
+		tok.Type = Whitespace
+		l.pos = start + 1
+		for l.pos < len(l.src) && isSpace(l.src[l.pos]) {
+			l.pos++
+		}
+	case strings.HasPrefix(rest, "--") || c == '#':
+		tok.Type = LineComment
+		end := strings.IndexByte(rest, '\n')
+		if end == -1 {
+			end = len(rest)
+		}
+		l.pos = start + end
+	case strings.HasPrefix(rest, "/*"):
+		tok.Type = BlockComment
+		l.pos, tok.Unterminated = l.scanBlockComment(start)
+	case c == '\'':
+		tok.Type = String
+		l.pos, tok.Unterminated = l.scanQuoted(start, c)
+	case c == '"' || c == '`':
+		tok.Type = QuotedIdentifier
+		l.pos, tok.Unterminated = l.scanQuoted(start, c)
+	case c == '$' && heredocTag(rest) != "":
+		tok.Type = Heredoc
+		tag := heredocTag(rest)
+		end := strings.Index(rest[len(tag):], tag)
+		if end == -1 {
+			l.pos = len(l.src)
+			tok.Unterminated = true
+		} else {
+			l.pos = start + len(tag) + end + len(tag)
+		}
+	case isDigit(c) || (c == '.' && len(rest) > 1 && isDigit(rest[1]) && !l.afterOperand()):
+		tok.Type = Number
+		l.pos = start + scanNumber(rest)
+	case isWordStart(rest):
+		tok.Type = Word
+		l.pos = start + scanWord(rest)
+	default:
+		tok.Type = Punct
+		l.pos = start + 1
+		for _, p := range multiCharPuncts {
+			if strings.HasPrefix(rest, p) {
+				l.pos = start + len(p)
+				break
+			}
+		}
+		if l.pos == start+1 && c >= utf8.RuneSelf {
+			_, size := utf8.DecodeRuneInString(rest)
+			l.pos = start + size
+		}
+	}
+
+	tok.Text = l.src[start:l.pos]
+	return tok, true
+}
+
+// afterOperand 判断当前位置前是否紧跟标识符或右括号，如 "t.1" 中的元组下标
+func (l *Lexer) afterOperand() bool {
+	if l.pos == 0 {
+		return false
+	}
+	prev, _ := utf8.DecodeLastRuneInString(l.src[:l.pos])
+	return prev == ')' || prev == ']' || prev == '_' || prev == '`' || prev == '"' ||
+		unicode.IsLetter(prev) || unicode.IsDigit(prev)
+}
+
+// scanBlockComment 扫描可嵌套的块注释
+func (l *Lexer) scanBlockComment(start int) (int, bool) {
+	level := 0
+	i := start
+	for i < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[i:], "/*"):
+			level++
+			i += 2
+		case strings.HasPrefix(l.src[i:], "*/"):
+			level--
+			i += 2
+			if level == 0 {
+				return i, false
+			}
+		default:
+			i++
+		}
+	}
+	return len(l.src), true
+}
+
+// scanQuoted 扫描引号包围的内容，支持反斜杠转义和双写引号转义
+func (l *Lexer) scanQuoted(start int, quote byte) (int, bool) {
+	i := start + 1
+	for i < len(l.src) {
+		switch l.src[i] {
+		case '\\':
+			i += 2
+		case quote:
+			if i+1 < len(l.src) && l.src[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1, false
+		default:
+			i++
+		}
+	}
+	return len(l.src), true
+}
+
+// heredocTag 返回以 $tag$ 开头的heredoc标记，不是heredoc时返回空串
+func heredocTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == '$' {
+			return s[:i+1]
+		}
+		if !isASCIIWordChar(c) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// scanNumber 扫描数字字面量: 整数、小数、指数以及0x/0b前缀
+func scanNumber(s string) int {
+	i := 0
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X' || s[1] == 'b' || s[1] == 'B') {
+		i = 2
+		for i < len(s) && (isHexDigit(s[i]) || s[i] == '_') {
+			i++
+		}
+		return i
+	}
+	for i < len(s) && (isDigit(s[i]) || s[i] == '_') {
+		i++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			i = j
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// scanWord 扫描关键字或标识符
+func scanWord(s string) int {
+	i := 0
+	for i < len(s) {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isWordRune(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+func isWordStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isASCIIWordChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
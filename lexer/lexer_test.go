@@ -0,0 +1,166 @@
+package lexer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	type tok struct {
+		typ  TokenType
+		text string
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []tok
+	}{
+		{
+			name:  "Простой запрос",
+			input: "SELECT 1",
+			want:  []tok{{Word, "SELECT"}, {Whitespace, " "}, {Number, "1"}},
+		},
+		{
+			name:  "Строка с экранированием",
+			input: `'it''s \' --'`,
+			want:  []tok{{String, `'it''s \' --'`}},
+		},
+		{
+			name:  "Идентификаторы в кавычках",
+			input: "`a``b`.\"c d\"",
+			want:  []tok{{QuotedIdentifier, "`a``b`"}, {Punct, "."}, {QuotedIdentifier, `"c d"`}},
+		},
+		{
+			name:  "Однострочные комментарии",
+			input: "-- a\n# b",
+			want:  []tok{{LineComment, "-- a"}, {Whitespace, "\n"}, {LineComment, "# b"}},
+		},
+		{
+			name:  "Вложенный блочный комментарий",
+			input: "/* a /* b */ c */x",
+			want:  []tok{{BlockComment, "/* a /* b */ c */"}, {Word, "x"}},
+		},
+		{
+			name:  "Heredoc без метки",
+			input: "$$ 'x' -- y $$",
+			want:  []tok{{Heredoc, "$$ 'x' -- y $$"}},
+		},
+		{
+			name:  "Heredoc с меткой",
+			input: "$tag$ $$ $tag$,",
+			want:  []tok{{Heredoc, "$tag$ $$ $tag$"}, {Punct, ","}},
+		},
+		{
+			name:  "Числа",
+			input: "1.5e-3 0xFF .5",
+			want: []tok{
+				{Number, "1.5e-3"}, {Whitespace, " "}, {Number, "0xFF"},
+				{Whitespace, " "}, {Number, ".5"},
+			},
+		},
+		{
+			name:  "Доступ к элементу кортежа",
+			input: "t.1",
+			want:  []tok{{Word, "t"}, {Punct, "."}, {Number, "1"}},
+		},
+		{
+			name:  "Составные операторы",
+			input: "a::String->b",
+			want:  []tok{{Word, "a"}, {Punct, "::"}, {Word, "String"}, {Punct, "->"}, {Word, "b"}},
+		},
+		{
+			name:  "Юникод в идентификаторе",
+			input: "SELECT поле",
+			want:  []tok{{Word, "SELECT"}, {Whitespace, " "}, {Word, "поле"}},
+		},
+		{
+			name:  "Параметр запроса",
+			input: "{id:UInt64}",
+			want:  []tok{{Punct, "{"}, {Word, "id"}, {Punct, ":"}, {Word, "UInt64"}, {Punct, "}"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Tokenize() = %v, want %v", got, tt.want)
+			}
+			for i, w := range tt.want {
+				if got[i].Type != w.typ || got[i].Text != w.text {
+					t.Errorf("Tokenize()[%d] = %v %q, want %v %q", i, got[i].Type, got[i].Text, w.typ, w.text)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenizeUnterminated(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		typ   TokenType
+	}{
+		{name: "Строка", input: "'abc", typ: String},
+		{name: "Идентификатор", input: "`abc", typ: QuotedIdentifier},
+		{name: "Комментарий", input: "/* a /* b */", typ: BlockComment},
+		{name: "Heredoc", input: "$x$ abc", typ: Heredoc},
+		{name: "Экранирование в конце", input: `'abc\`, typ: String},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Tokenize(tt.input)
+			if len(got) != 1 {
+				t.Fatalf("Tokenize() = %v, want один токен", got)
+			}
+			if got[0].Type != tt.typ || !got[0].Unterminated || got[0].Text != tt.input {
+				t.Errorf("Tokenize() = %+v, want незакрытый %v", got[0], tt.typ)
+			}
+		})
+	}
+}
+
+func TestTokenHelpers(t *testing.T) {
+	tokens := Significant(Tokenize("select /* c */ x -- d\n;"))
+	if len(tokens) != 3 {
+		t.Fatalf("Significant() = %v, want 3 токена", tokens)
+	}
+	if !tokens[0].IsKeyword("SELECT") {
+		t.Errorf("IsKeyword(SELECT) = false для %q", tokens[0].Text)
+	}
+	if !tokens[2].IsPunct(";") {
+		t.Errorf("IsPunct(;) = false для %q", tokens[2].Text)
+	}
+	if tokens[1].Pos != 15 || tokens[1].End() != 16 {
+		t.Errorf("позиция x = %d..%d, want 15..16", tokens[1].Pos, tokens[1].End())
+	}
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{
+		"SELECT 1", "'a''b'", "/* /* */", "$$x$$", "$a$ $b$", "`x", "-- c\n#d", "0x1F.5e+", "t.1.2", "\xff\xfe",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		var b strings.Builder
+		pos := 0
+		for _, tok := range Tokenize(input) {
+			if tok.Text == "" {
+				t.Fatalf("пустой токен в позиции %d для %q", tok.Pos, input)
+			}
+			if tok.Pos != pos {
+				t.Fatalf("токен %q в позиции %d, want %d", tok.Text, tok.Pos, pos)
+			}
+			pos = tok.End()
+			b.WriteString(tok.Text)
+		}
+		// Склейка токенов должна восстанавливать исходный текст
+		if b.String() != input {
+			t.Errorf("склейка токенов = %q, want %q", b.String(), input)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 
@@ -9,58 +11,42 @@ import (
 )
 
 func main() {
-	// Определение флагов командной строки
-	var (
-		transport     string
-		testMode      bool
-		clickhouseURL string
-		username      string
-		password      string
-		database      string
-		secure        bool
-		port          int
-	)
-
-	// Настройки транспорта и тестового режима
-	flag.StringVar(&transport, "t", "stdio", "Transport type (stdio or sse)")
-	flag.StringVar(&transport, "transport", "stdio", "Transport type (stdio or sse)")
-	flag.BoolVar(&testMode, "test", false, "Run in test mode")
-	flag.IntVar(&port, "port", 8082, "Port for SSE server")
+	// Режим "config print" выводит итоговую конфигурацию без запуска сервера
+	args := os.Args[1:]
+	printConfig := false
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) < 2 || args[1] != "print" {
+			fmt.Fprintln(os.Stderr, "Usage: clickhouse-mcp config print [flags]")
+			os.Exit(2)
+		}
+		printConfig, args = true, args[2:]
+	}
 
-	// Настройки подключения к ClickHouse
-	flag.StringVar(&clickhouseURL, "url", "localhost:9000/default", "ClickHouse URL (format: host:port/database)")
-	flag.StringVar(&username, "user", "default", "ClickHouse username")
-	flag.StringVar(&password, "password", "", "ClickHouse password")
-	flag.StringVar(&database, "db", "", "ClickHouse database (overrides database in URL)")
-	flag.BoolVar(&secure, "secure", false, "Use TLS connection")
+	// Конфигурация: файл, затем переменные окружения CLICKHOUSE_*, затем флаги командной строки
+	loaded, err := app.LoadConfig(args, os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка конфигурации:", err)
+		os.Exit(2)
+	}
 
-	flag.Parse()
+	if printConfig {
+		if err := loaded.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Ошибка вывода конфигурации:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Настраиваем текстовый логгер
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	// Настраиваем текстовый логгер. Stdout занят потоком JSON-RPC транспорта stdio, поэтому лог пишется в stderr
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})))
 
-	// Проверка обязательных параметров
-	if clickhouseURL == "" {
-		slog.Error("URL ClickHouse не указан. Используйте флаг -url")
-		os.Exit(1)
-	}
-
-	// Конфигурация сервера
-	config := app.ServerConfig{
-		Transport:     transport,
-		TestMode:      testMode,
-		ClickhouseURL: clickhouseURL,
-		Username:      username,
-		Password:      password,
-		Database:      database,
-		Secure:        secure,
-		Port:          port,
-	}
-
 	// Создаем и запускаем сервер
-	server, err := app.NewServer(config)
+	server, err := app.NewServer(loaded.Server)
 	if err != nil {
 		slog.Error("Ошибка создания сервера", "err", err)
 		os.Exit(1)
@@ -0,0 +1,259 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"clickhouse-mcp/clickhouse"
+)
+
+// bytesPerToken 估算token数时每个token对应的平均字节数
+const bytesPerToken = 4
+
+// minCellBytes 单元格截断后至少保留的字节数
+const minCellBytes = 64
+
+// ResponseBudget 工具响应的大小预算，字节和token同时设置时取较严格的一个
+type ResponseBudget struct {
+	// MaxBytes 响应的最大字节数，0表示不限制
+	MaxBytes int
+	// MaxTokens 响应的最大token数(按每token约4字节估算)，0表示不限制
+	MaxTokens int
+}
+
+// Limit 返回预算对应的最大字节数，0表示不限制
+func (b ResponseBudget) Limit() int {
+	return minLimit(b.MaxBytes, b.MaxTokens*bytesPerToken)
+}
+
+// Tighten 返回同时满足两个预算的预算，单次调用只能收紧服务器的预算
+func (b ResponseBudget) Tighten(other ResponseBudget) ResponseBudget {
+	return ResponseBudget{
+		MaxBytes:  minLimit(b.MaxBytes, other.MaxBytes),
+		MaxTokens: minLimit(b.MaxTokens, other.MaxTokens),
+	}
+}
+
+// minLimit 返回非零值中较小的一个，都为0时返回0
+func minLimit(a, b int) int {
+	switch {
+	case a <= 0:
+		return max(b, 0)
+	case b <= 0:
+		return a
+	default:
+		return min(a, b)
+	}
+}
+
+// omission 描述为满足响应预算而省略的内容
+type omission struct {
+	// BudgetBytes 生效的响应预算(字节)
+	BudgetBytes int `json:"budget_bytes,omitempty"`
+	// Rows 已读取但未返回的行数
+	Rows int `json:"rows,omitempty"`
+	// MoreRows 读取提前停止，服务端可能还有未读取的行
+	MoreRows bool `json:"more_rows,omitempty"`
+	// Cells 被截断的单元格数
+	Cells int `json:"cells,omitempty"`
+	// CellBytes 截断单元格时省略的字节数
+	CellBytes int `json:"cell_bytes,omitempty"`
+	// Columns 只保留列数、未返回列信息和数据的列数
+	Columns int `json:"columns,omitempty"`
+}
+
+// empty 是否没有省略任何内容
+func (o *omission) empty() bool {
+	return o == nil || *o == omission{BudgetBytes: o.BudgetBytes}
+}
+
+// rowBudget 在流式读取查询结果时限制收集的数据量，超出预算后停止读取
+type rowBudget struct {
+	// maxBytes 行数据按JSON编码后的最大字节数，0表示不限制
+	maxBytes int
+	// maxCellBytes 字符串的最大字节数，超出部分被截断，包括Array、Map和Tuple中的字符串，0表示不限制
+	maxCellBytes int
+	bytes        int
+	rows         [][]any
+	// exceeded 因超出字节预算而停止读取
+	exceeded bool
+	// cells 被截断的字符串数，cellBytes 截断省略的字节数
+	cells     int
+	cellBytes int
+}
+
+// add 收集一行，超出字节预算时丢弃该行并返回ErrStopQuery
+func (b *rowBudget) add(row []any) error {
+	if b.maxCellBytes > 0 {
+		for i, v := range row {
+			row[i] = b.truncate(v)
+		}
+	}
+
+	if b.maxBytes > 0 {
+		size, err := rowSize(row)
+		if err != nil {
+			return err
+		}
+		if b.bytes+size > b.maxBytes {
+			b.exceeded = true
+			return clickhouse.ErrStopQuery
+		}
+		b.bytes += size
+	}
+	b.rows = append(b.rows, row)
+	return nil
+}
+
+// truncate 截断超过maxCellBytes的字符串，嵌套的数组和对象(Array、Map、Tuple、JSON)中的字符串
+// 原地截断，对象的键不截断
+func (b *rowBudget) truncate(v any) any {
+	switch v := v.(type) {
+	case string:
+		if len(v) <= b.maxCellBytes {
+			return v
+		}
+		cut := truncateUTF8(v, b.maxCellBytes)
+		b.cells++
+		b.cellBytes += len(v) - len(cut)
+		return cut + "…"
+	case []any:
+		for i, item := range v {
+			v[i] = b.truncate(item)
+		}
+	case map[string]any:
+		for key, item := range v {
+			v[key] = b.truncate(item)
+		}
+	}
+	return v
+}
+
+// truncateUTF8 截断字符串到最多n字节，不拆分UTF-8字符
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// rowSize 返回行按JSON编码后的字节数，用于估算响应大小
+func rowSize(row []any) (int, error) {
+	line, err := marshalJSON(row)
+	if err != nil {
+		return 0, err
+	}
+	return len(line), nil
+}
+
+// cellLimit 按响应预算确定单元格的最大字节数: 单个单元格最多占预算的1/8
+func cellLimit(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	return max(limit/8, minCellBytes)
+}
+
+// contentSize 返回响应各部分的总字节数
+func contentSize(parts []string) int {
+	size := 0
+	for _, part := range parts {
+		size += len(part)
+	}
+	return size
+}
+
+// fitResult 按预算渲染查询结果: 先从末尾丢弃行，仍然超出时只保留部分列的信息。
+// omitted记录省略的内容并包含在渲染结果中
+func fitResult(result clickhouse.QueryResult, format OutputFormat, limit int, omitted *omission) ([]string, error) {
+	render := func(r clickhouse.QueryResult) ([]string, error) {
+		if omitted.empty() {
+			return renderResult(r, format, nil)
+		}
+		r.Truncated = true
+		return renderResult(r, format, omitted)
+	}
+
+	parts, err := render(result)
+	if err != nil || limit <= 0 || contentSize(parts) <= limit {
+		return parts, err
+	}
+
+	// 二分查找能放入预算的最多行数
+	rows := result.Rows
+	var renderErr error
+	keep := sort.Search(len(rows)+1, func(n int) bool {
+		// 查找第一个放不下的行数
+		omitted.Rows = len(rows) - n
+		r := result
+		r.Rows = rows[:n]
+		parts, err := render(r)
+		if err != nil {
+			renderErr = err
+			return true
+		}
+		return contentSize(parts) > limit
+	}) - 1
+	if renderErr != nil {
+		return nil, renderErr
+	}
+
+	if keep >= 0 {
+		omitted.Rows = len(rows) - keep
+		result.Rows = rows[:keep]
+		return render(result)
+	}
+
+	// 没有行也放不下时只保留前面部分列的信息
+	omitted.Rows = len(rows)
+	result.Rows = [][]any{}
+	columns := result.Columns
+	keepColumns := sort.Search(len(columns)+1, func(n int) bool {
+		omitted.Columns = len(columns) - n
+		r := result
+		r.Columns = columns[:n]
+		parts, err := render(r)
+		if err != nil {
+			renderErr = err
+			return true
+		}
+		return contentSize(parts) > limit
+	}) - 1
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	keepColumns = max(keepColumns, 0)
+	omitted.Columns = len(columns) - keepColumns
+	result.Columns = columns[:keepColumns]
+	return render(result)
+}
+
+// fitList 按预算输出带标题的列表，超出预算时省略末尾的条目并注明省略数量
+func fitList(header string, items []string, limit int, noun string) string {
+	build := func(n int) string {
+		var b strings.Builder
+		b.WriteString(header)
+		for _, item := range items[:n] {
+			b.WriteString(item)
+		}
+		if n < len(items) {
+			fmt.Fprintf(&b, "\n... 超出响应预算(%d字节)，省略了%d个%s(共%d个)\n", limit, len(items)-n, noun, len(items))
+		}
+		return b.String()
+	}
+
+	text := build(len(items))
+	if limit <= 0 || len(text) <= limit {
+		return text
+	}
+
+	keep := sort.Search(len(items)+1, func(n int) bool {
+		return len(build(n)) > limit
+	}) - 1
+	return build(max(keep, 0))
+}
@@ -0,0 +1,166 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+
+	"clickhouse-mcp/clickhouse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRowBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxBytes     int
+		rows         [][]any
+		wantRows     int
+		wantExceeded bool
+	}{
+		{
+			name:     "Без ограничения",
+			rows:     [][]any{{"aaaa"}, {"bbbb"}, {"cccc"}},
+			wantRows: 3,
+		},
+		{
+			// Каждая строка занимает 8 байт: ["aaaa"]
+			name:     "Строки помещаются точно",
+			maxBytes: 16,
+			rows:     [][]any{{"aaaa"}, {"bbbb"}},
+			wantRows: 2,
+		},
+		{
+			name:         "Бюджет превышен",
+			maxBytes:     20,
+			rows:         [][]any{{"aaaa"}, {"bbbb"}, {"cccc"}},
+			wantRows:     2,
+			wantExceeded: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &rowBudget{maxBytes: tt.maxBytes}
+			var err error
+			for _, row := range tt.rows {
+				if err = budget.add(row); err != nil {
+					break
+				}
+			}
+			if tt.wantExceeded {
+				assert.ErrorIs(t, err, clickhouse.ErrStopQuery)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Len(t, budget.rows, tt.wantRows)
+			assert.Equal(t, tt.wantExceeded, budget.exceeded)
+		})
+	}
+}
+
+func TestResponseBudget(t *testing.T) {
+	tests := []struct {
+		name   string
+		server ResponseBudget
+		call   ResponseBudget
+		want   int
+	}{
+		{name: "Без ограничения"},
+		{name: "Только байты", server: ResponseBudget{MaxBytes: 1000}, want: 1000},
+		{name: "Токены строже байтов", server: ResponseBudget{MaxBytes: 1000, MaxTokens: 100}, want: 400},
+		{name: "Вызов сужает бюджет", server: ResponseBudget{MaxBytes: 1000}, call: ResponseBudget{MaxBytes: 500}, want: 500},
+		{name: "Вызов не расширяет бюджет", server: ResponseBudget{MaxBytes: 1000}, call: ResponseBudget{MaxBytes: 5000}, want: 1000},
+		{name: "Токены вызова", server: ResponseBudget{MaxBytes: 1000}, call: ResponseBudget{MaxTokens: 50}, want: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.server.Tighten(tt.call).Limit())
+		})
+	}
+}
+
+func TestRowBudgetCells(t *testing.T) {
+	budget := &rowBudget{maxCellBytes: 4}
+
+	// "ПРИВЕТ" занимает 12 байт, обрезка не должна разрывать символ
+	assert.NoError(t, budget.add([]any{"abcdef", uint8(1), "ПРИВЕТ", "abc"}))
+
+	assert.Equal(t, [][]any{{"abcd…", uint8(1), "ПР…", "abc"}}, budget.rows)
+	assert.Equal(t, 2, budget.cells)
+	assert.Equal(t, 2+8, budget.cellBytes)
+}
+
+func TestRowBudgetNestedCells(t *testing.T) {
+	budget := &rowBudget{maxCellBytes: 4}
+
+	// Строки внутри Array, Map и Tuple обрезаются так же, ключи объектов сохраняются
+	row := []any{
+		[]any{"abcdef", "ab", []any{"abcdefgh"}},
+		map[string]any{"long_key": "abcdef", "n": uint8(1)},
+		map[string]any{"name": "ПРИВЕТ", "tags": []any{"xyz", "uvwxyz"}},
+	}
+	assert.NoError(t, budget.add(row))
+
+	assert.Equal(t, [][]any{{
+		[]any{"abcd…", "ab", []any{"abcd…"}},
+		map[string]any{"long_key": "abcd…", "n": uint8(1)},
+		map[string]any{"name": "ПР…", "tags": []any{"xyz", "uvwx…"}},
+	}}, budget.rows)
+	assert.Equal(t, 5, budget.cells)
+	assert.Equal(t, 2+4+2+8+2, budget.cellBytes)
+}
+
+func TestFitResult(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	result := clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{
+			{Name: "a", Type: "String", Position: 1},
+			{Name: "b", Type: "String", Position: 2},
+		},
+		Rows: [][]any{{"1", long}, {"2", long}, {"3", long}},
+	}
+
+	t.Run("Помещается целиком", func(t *testing.T) {
+		omitted := &omission{BudgetBytes: 1000}
+		parts, err := fitResult(result, FormatCSV, 1000, omitted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "a,b\n1,"+long+"\n2,"+long+"\n3,"+long+"\n", parts[0])
+		assert.NotContains(t, parts[1], "omitted")
+	})
+
+	t.Run("Отбрасываются строки", func(t *testing.T) {
+		omitted := &omission{BudgetBytes: 220}
+		parts, err := fitResult(result, FormatCSV, 220, omitted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "a,b\n1,"+long+"\n", parts[0])
+		assert.Equal(t, 2, omitted.Rows)
+		assert.Contains(t, parts[1], `"truncated":true`)
+		assert.Contains(t, parts[1], `"omitted":{"budget_bytes":220,"rows":2}`)
+		assert.LessOrEqual(t, contentSize(parts), 220)
+	})
+
+	t.Run("Отбрасываются столбцы", func(t *testing.T) {
+		omitted := &omission{BudgetBytes: 140}
+		parts, err := fitResult(result, FormatCompact, 140, omitted)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"columns":[{"name":"a","type":"String","position":1}],"rows":[],"truncated":true,` +
+			`"omitted":{"budget_bytes":140,"rows":3,"columns":1}}`}, parts)
+	})
+}
+
+func TestFitList(t *testing.T) {
+	long := strings.Repeat("x", 40)
+	items := []string{"1. " + long + "\n", "2. " + long + "\n", "3. " + long + "\n"}
+	full := "T:\n" + strings.Join(items, "")
+
+	assert.Equal(t, full, fitList("T:\n", items, 0, "表"))
+	assert.Equal(t, full, fitList("T:\n", items, len(full), "表"))
+
+	got := fitList("T:\n", items, 120, "表")
+	assert.Equal(t, "T:\n"+items[0]+"\n... 超出响应预算(120字节)，省略了2个表(共3个)\n", got)
+	assert.LessOrEqual(t, len(got), 120)
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+
+	"clickhouse-mcp/clickhouse"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// errorHints 常见ClickHouse错误的处理建议，按错误码名称索引
+var errorHints = map[string][]string{
+	"UNKNOWN_TABLE": {
+		"用get_tables工具查看数据库中的表，检查表名的拼写和大小写",
+		"表名前加上数据库名，如db.table",
+	},
+	"UNKNOWN_DATABASE": {
+		"用get_databases工具查看可用的数据库",
+	},
+	"UNKNOWN_IDENTIFIER": {
+		"用get_schema工具查看表的列名，检查列名的拼写和大小写",
+		"别名只能在定义它的SELECT中引用",
+	},
+	"NO_SUCH_COLUMN_IN_TABLE": {
+		"用get_schema工具查看表的列名",
+	},
+	"THERE_IS_NO_COLUMN": {
+		"用get_schema工具查看表的列名",
+	},
+	"UNKNOWN_FUNCTION": {
+		"检查函数名的拼写，部分函数名区分大小写",
+		"可用的函数见system.functions表",
+	},
+	"SYNTAX_ERROR": {
+		"用validate_query工具定位语法错误的行和列",
+		"标识符中的特殊字符和保留字用反引号括起来，字符串用单引号",
+	},
+	"TIMEOUT_EXCEEDED": {
+		"添加过滤条件缩小时间范围，或按分区键和主键过滤以减少读取的数据",
+		"用explain工具的ESTIMATE查看查询将读取的行数",
+		"在settings参数中增大max_execution_time(不超过服务器允许的上限)",
+	},
+	"MEMORY_LIMIT_EXCEEDED": {
+		"减少GROUP BY键的基数或只选择需要的列",
+		"JOIN时把较小的表放在右侧，或先在子查询中过滤和聚合",
+		"添加过滤条件或LIMIT减少处理的数据量",
+		"在settings参数中增大max_memory_usage(不超过服务器允许的上限)",
+	},
+	"TOO_MANY_ROWS": {
+		"添加过滤条件减少读取的行数，或在settings参数中增大max_rows_to_read",
+	},
+	"TOO_MANY_BYTES": {
+		"只选择需要的列并添加过滤条件，或在settings参数中增大max_bytes_to_read",
+	},
+	"TOO_MANY_ROWS_OR_BYTES": {
+		"添加过滤条件减少读取的数据，或在settings参数中增大max_rows_to_read和max_bytes_to_read",
+	},
+	"NOT_AN_AGGREGATE": {
+		"SELECT中的非聚合列必须出现在GROUP BY中，或用any()、max()等聚合函数包裹",
+	},
+	"ILLEGAL_AGGREGATION": {
+		"聚合函数不能嵌套，也不能出现在WHERE中；对聚合结果过滤请使用HAVING",
+	},
+	"TYPE_MISMATCH": {
+		"用get_schema工具查看列的类型，用toString()、toInt64()等函数显式转换",
+	},
+	"ILLEGAL_TYPE_OF_ARGUMENT": {
+		"用get_schema工具查看列的类型，用toString()、toInt64()等函数显式转换",
+	},
+	"NO_COMMON_TYPE": {
+		"UNION、CASE、if()和数组的各分支需要兼容的类型，用CAST统一类型",
+	},
+	"UNKNOWN_QUERY_PARAMETER": {
+		"为查询中的每个{名称:类型}占位符在params参数中提供值",
+	},
+	"BAD_QUERY_PARAMETER": {
+		"检查params参数的值是否符合占位符的类型",
+	},
+	"READONLY": {
+		"服务器只允许只读查询",
+	},
+	"ACCESS_DENIED": {
+		"当前用户没有该权限，请改用有权限的表或联系管理员",
+	},
+	"TOO_MANY_SIMULTANEOUS_QUERIES": {
+		"服务器繁忙，稍后重试",
+	},
+	"QUERY_WAS_CANCELLED": {
+		"查询被取消，如需要可重新执行",
+	},
+}
+
+// errorResult 工具错误的结构化表示
+type errorResult struct {
+	// Error 失败的操作
+	Error string `json:"error"`
+	// Code、Name ClickHouse错误码及其名称
+	Code    int32  `json:"code,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Message string `json:"message"`
+	// Line、Column 语法错误在查询中的位置
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+	// StackTrace 服务端的调用栈，仅在配置允许时返回
+	StackTrace string `json:"stack_trace,omitempty"`
+	// Exceeded、Estimate、Limits 估算成本超限时超出的上限、估算结果和上限配置
+	Exceeded []string                 `json:"exceeded,omitempty"`
+	Estimate *clickhouse.CostEstimate `json:"estimate,omitempty"`
+	Limits   *clickhouse.CostLimits   `json:"limits,omitempty"`
+	// Hints 处理建议
+	Hints []string `json:"hints,omitempty"`
+}
+
+// toolError 返回工具错误。ClickHouse异常、语法错误和估算成本超限以JSON返回，
+// 包含错误码和处理建议，其他错误返回"操作: 错误信息"文本
+func (h *DefaultToolHandler) toolError(operation string, err error) *mcp.CallToolResult {
+	var (
+		syntaxErr *clickhouse.SyntaxError
+		serverErr *clickhouse.ServerError
+		costErr   *clickhouse.CostLimitError
+		result    = errorResult{Error: operation}
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		result.Code = syntaxErr.Code
+		result.Name = syntaxErr.Name
+		result.Message = syntaxErr.Message
+		result.Line = syntaxErr.Line
+		result.Column = syntaxErr.Column
+		result.Hints = errorHints[syntaxErr.Name]
+	case errors.As(err, &serverErr):
+		result.Code = serverErr.Code
+		result.Name = serverErr.Name
+		result.Message = serverErr.Message
+		result.Hints = errorHints[serverErr.Name]
+		if h.config.StackTraces {
+			result.StackTrace = serverErr.StackTrace
+		}
+	case errors.As(err, &costErr):
+		result.Message = "查询被拒绝，估算成本超出限制"
+		result.Exceeded = costErr.Exceeded
+		result.Estimate = &costErr.Estimate
+		result.Limits = &costErr.Limits
+		result.Hints = costErr.Suggestions
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("%s: %s", operation, err))
+	}
+
+	text, jsonErr := marshalIndentJSON(result)
+	if jsonErr != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("%s: %s", operation, err))
+	}
+	return mcp.NewToolResultError(text)
+}
@@ -0,0 +1,69 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"clickhouse-mcp/clickhouse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolError(t *testing.T) {
+	serverErr := fmt.Errorf("查询执行失败: %w", &clickhouse.ServerError{
+		Code:       159,
+		Name:       "TIMEOUT_EXCEEDED",
+		Message:    "Timeout exceeded: elapsed 10.1 seconds, maximum: 10",
+		StackTrace: "0. DB::Exception::Exception()",
+	})
+
+	t.Run("Ошибка ClickHouse с подсказками", func(t *testing.T) {
+		handler := &DefaultToolHandler{}
+		result := handler.toolError("执行查询错误", serverErr)
+
+		assert.True(t, result.IsError)
+		var parsed errorResult
+		assert.NoError(t, json.Unmarshal([]byte(getText(result)), &parsed))
+		assert.Equal(t, "执行查询错误", parsed.Error)
+		assert.Equal(t, int32(159), parsed.Code)
+		assert.Equal(t, "TIMEOUT_EXCEEDED", parsed.Name)
+		assert.Equal(t, "Timeout exceeded: elapsed 10.1 seconds, maximum: 10", parsed.Message)
+		assert.Equal(t, errorHints["TIMEOUT_EXCEEDED"], parsed.Hints)
+		assert.Empty(t, parsed.StackTrace)
+	})
+
+	t.Run("Стек вызовов по настройке", func(t *testing.T) {
+		handler := &DefaultToolHandler{config: ToolConfig{StackTraces: true}}
+		result := handler.toolError("执行查询错误", serverErr)
+
+		assert.Contains(t, getText(result), `"stack_trace": "0. DB::Exception::Exception()"`)
+	})
+
+	t.Run("Превышение оценки стоимости", func(t *testing.T) {
+		handler := &DefaultToolHandler{}
+		result := handler.toolError("执行查询错误", &clickhouse.CostLimitError{
+			Estimate:    clickhouse.CostEstimate{Rows: 5000, Parts: 3},
+			Limits:      clickhouse.CostLimits{MaxRows: 1000},
+			Exceeded:    []string{"读取行数5000超过上限1000"},
+			Suggestions: []string{"缩小时间范围"},
+		})
+
+		assert.True(t, result.IsError)
+		var parsed errorResult
+		assert.NoError(t, json.Unmarshal([]byte(getText(result)), &parsed))
+		assert.Equal(t, []string{"读取行数5000超过上限1000"}, parsed.Exceeded)
+		assert.Equal(t, uint64(5000), parsed.Estimate.Rows)
+		assert.Equal(t, uint64(1000), parsed.Limits.MaxRows)
+		assert.Equal(t, []string{"缩小时间范围"}, parsed.Hints)
+	})
+
+	t.Run("Прочие ошибки остаются текстом", func(t *testing.T) {
+		handler := &DefaultToolHandler{}
+		result := handler.toolError("执行查询错误", errors.New("连接错误"))
+
+		assert.True(t, result.IsError)
+		assert.Equal(t, "执行查询错误: 连接错误", getText(result))
+	})
+}
@@ -0,0 +1,390 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"clickhouse-mcp/clickhouse"
+)
+
+// OutputFormat query工具结果的输出格式
+type OutputFormat string
+
+const (
+	// FormatJSON 每行一个以列名为键的对象，同名列只保留最后一列
+	FormatJSON OutputFormat = "json"
+	// FormatCompact 列信息加按列顺序排列的行数组，保留列顺序和同名列，占用更少的token
+	FormatCompact OutputFormat = "compact"
+	// FormatJSONL 每行一个JSON对象(JSON Lines)
+	FormatJSONL OutputFormat = "jsonl"
+	// FormatCSV 带表头的CSV，按RFC 4180转义
+	FormatCSV OutputFormat = "csv"
+	// FormatTSV 带表头的TSV，按ClickHouse TabSeparated规则转义
+	FormatTSV OutputFormat = "tsv"
+	// FormatMarkdown Markdown表格
+	FormatMarkdown OutputFormat = "markdown"
+)
+
+// outputFormats 支持的输出格式
+var outputFormats = []OutputFormat{FormatJSON, FormatCompact, FormatJSONL, FormatCSV, FormatTSV, FormatMarkdown}
+
+// ParseOutputFormat 解析输出格式名称，空串表示默认的json
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return FormatJSON, nil
+	}
+	for _, format := range outputFormats {
+		if string(format) == name {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("未知的输出格式: %q", name)
+}
+
+// formatNames 返回所有输出格式名称
+func formatNames() []string {
+	names := make([]string, len(outputFormats))
+	for i, format := range outputFormats {
+		names[i] = string(format)
+	}
+	return names
+}
+
+// hasMetadata 格式本身是否包含列类型等元数据，不包含时需要单独返回
+func (f OutputFormat) hasMetadata() bool {
+	return f == FormatJSON || f == FormatCompact
+}
+
+// objectResult json格式的查询结果，每行为一个对象
+type objectResult struct {
+	Columns   []clickhouse.ColumnInfo `json:"columns"`
+	Rows      []map[string]any        `json:"rows"`
+	Truncated bool                    `json:"truncated"`
+	Timezone  string                  `json:"timezone,omitempty"`
+	Omitted   *omission               `json:"omitted,omitempty"`
+	Stats     *clickhouse.QueryStats  `json:"stats,omitempty"`
+}
+
+// compactResult compact格式的查询结果，每行为按列顺序排列的数组
+type compactResult struct {
+	Columns   []clickhouse.ColumnInfo `json:"columns"`
+	Rows      [][]any                 `json:"rows"`
+	Truncated bool                    `json:"truncated"`
+	Timezone  string                  `json:"timezone,omitempty"`
+	Omitted   *omission               `json:"omitted,omitempty"`
+	Stats     *clickhouse.QueryStats  `json:"stats,omitempty"`
+}
+
+// resultMetadata 文本格式结果附带的元数据
+type resultMetadata struct {
+	Columns   []clickhouse.ColumnInfo `json:"columns"`
+	Rows      int                     `json:"rows"`
+	Truncated bool                    `json:"truncated"`
+	Timezone  string                  `json:"timezone,omitempty"`
+	Omitted   *omission               `json:"omitted,omitempty"`
+	Stats     *clickhouse.QueryStats  `json:"stats,omitempty"`
+}
+
+// renderResult 按输出格式渲染查询结果，返回响应的各个文本部分。
+// 表格类格式不包含列类型等元数据，元数据作为单独的部分返回
+func renderResult(result clickhouse.QueryResult, format OutputFormat, omitted *omission) ([]string, error) {
+	text, err := formatResult(result, format, omitted)
+	if err != nil {
+		return nil, err
+	}
+	if format.hasMetadata() {
+		return []string{text}, nil
+	}
+	metadata, err := formatMetadata(result, omitted)
+	if err != nil {
+		return nil, err
+	}
+	return []string{text, metadata}, nil
+}
+
+// formatResult 按输出格式序列化查询结果
+func formatResult(result clickhouse.QueryResult, format OutputFormat, omitted *omission) (string, error) {
+	switch format {
+	case FormatCompact:
+		return marshalJSON(compactResult{
+			Columns:   result.Columns,
+			Rows:      result.Rows,
+			Truncated: result.Truncated,
+			Timezone:  result.Timezone,
+			Omitted:   omitted,
+			Stats:     result.Stats,
+		})
+	case FormatJSONL:
+		return formatJSONL(result)
+	case FormatCSV:
+		return formatCSV(result)
+	case FormatTSV:
+		return formatTSV(result)
+	case FormatMarkdown:
+		return formatMarkdown(result)
+	default:
+		return marshalIndentJSON(objectResult{
+			Columns:   result.Columns,
+			Rows:      rowObjects(result),
+			Truncated: result.Truncated,
+			Timezone:  result.Timezone,
+			Omitted:   omitted,
+			Stats:     result.Stats,
+		})
+	}
+}
+
+// formatMetadata 序列化文本格式结果的元数据: 列类型、行数、截断标记、省略的内容和执行统计
+func formatMetadata(result clickhouse.QueryResult, omitted *omission) (string, error) {
+	return marshalJSON(resultMetadata{
+		Columns:   result.Columns,
+		Rows:      len(result.Rows),
+		Truncated: result.Truncated,
+		Timezone:  result.Timezone,
+		Omitted:   omitted,
+		Stats:     result.Stats,
+	})
+}
+
+// rowObjects 将按位置排列的行转换为以列名为键的对象
+func rowObjects(result clickhouse.QueryResult) []map[string]any {
+	rows := make([]map[string]any, len(result.Rows))
+	for i, values := range result.Rows {
+		row := make(map[string]any, len(result.Columns))
+		for j, col := range result.Columns {
+			if j < len(values) {
+				row[col.Name] = values[j]
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// formatJSONL 每行输出一个JSON对象
+func formatJSONL(result clickhouse.QueryResult) (string, error) {
+	var b strings.Builder
+	for _, row := range rowObjects(result) {
+		line, err := marshalJSON(row)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// formatCSV 输出带表头的CSV
+func formatCSV(result clickhouse.QueryResult) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(columnNames(result)); err != nil {
+		return "", err
+	}
+	for _, row := range result.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			cell, err := cellText(v, `\N`)
+			if err != nil {
+				return "", err
+			}
+			record[i] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// tsvEscaper TabSeparated格式的转义规则
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// formatTSV 输出带表头的TSV
+func formatTSV(result clickhouse.QueryResult) (string, error) {
+	var b strings.Builder
+	writeLine := func(cells []string) {
+		for i, cell := range cells {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(cell)
+		}
+		b.WriteByte('\n')
+	}
+
+	header := columnNames(result)
+	for i, name := range header {
+		header[i] = tsvEscaper.Replace(name)
+	}
+	writeLine(header)
+
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			if v == nil {
+				// NULL不转义，与字符串"\N"区分
+				cells[i] = `\N`
+				continue
+			}
+			cell, err := cellText(v, "")
+			if err != nil {
+				return "", err
+			}
+			cells[i] = tsvEscaper.Replace(cell)
+		}
+		writeLine(cells)
+	}
+	return b.String(), nil
+}
+
+// markdownEscaper Markdown表格单元格的转义规则
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// formatMarkdown 输出Markdown表格
+func formatMarkdown(result clickhouse.QueryResult) (string, error) {
+	var b strings.Builder
+	writeLine := func(cells []string) {
+		b.WriteString("|")
+		for _, cell := range cells {
+			b.WriteString(" ")
+			b.WriteString(cell)
+			b.WriteString(" |")
+		}
+		b.WriteByte('\n')
+	}
+
+	header := columnNames(result)
+	separator := make([]string, len(header))
+	for i, name := range header {
+		header[i] = markdownEscaper.Replace(name)
+		separator[i] = "---"
+	}
+	writeLine(header)
+	writeLine(separator)
+
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cell, err := cellText(v, "NULL")
+			if err != nil {
+				return "", err
+			}
+			cells[i] = markdownEscaper.Replace(cell)
+		}
+		writeLine(cells)
+	}
+	return b.String(), nil
+}
+
+// columnNames 返回列名列表
+func columnNames(result clickhouse.QueryResult) []string {
+	names := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// cellText 将单元格值转换为文本: 字符串原样输出，NULL输出指定的占位符，
+// 其他值(数字、布尔值、数组、Map、Tuple等)输出其JSON表示，各文本格式保持一致
+func cellText(v any, null string) (string, error) {
+	switch val := v.(type) {
+	case nil:
+		return null, nil
+	case string:
+		return val, nil
+	}
+	return marshalJSON(v)
+}
+
+// marshalJSON 序列化为单行JSON，不转义HTML字符
+func marshalJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// marshalIndentJSON 序列化为缩进的JSON，不转义HTML字符，单元格和查询片段中的<、>和&保持原样
+func marshalIndentJSON(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// formatExplain 格式化EXPLAIN结果，返回标题、按行拆分的内容和结尾，便于按响应预算截断。
+// 单列结果(计划、管道、语法树等)放入代码块，graph=1时为DOT图，json=1时为JSON；
+// 多列结果(如ESTIMATE)输出为Markdown表格
+func formatExplain(kind clickhouse.ExplainKind, options map[string]any, result clickhouse.QueryResult) (string, []string, string, error) {
+	header := fmt.Sprintf("EXPLAIN %s结果:\n\n", kind)
+
+	if len(result.Columns) != 1 {
+		table, err := formatMarkdown(result)
+		if err != nil {
+			return "", nil, "", err
+		}
+		lines := strings.SplitAfter(table, "\n")
+		return header, lines[:len(lines)-1], "", nil
+	}
+
+	lang := "text"
+	switch {
+	case explainFlag(options, "graph"):
+		lang = "dot"
+	case explainFlag(options, "json"):
+		lang = "json"
+	}
+
+	lines := make([]string, len(result.Rows))
+	for i, row := range result.Rows {
+		text, err := cellText(row[0], "NULL")
+		if err != nil {
+			return "", nil, "", err
+		}
+		lines[i] = text + "\n"
+	}
+	return header + "```" + lang + "\n", lines, "```\n", nil
+}
+
+// explainFlag EXPLAIN选项是否开启，选项值为布尔值或整数
+func explainFlag(options map[string]any, name string) bool {
+	switch v := options[name].(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	}
+	return false
+}
+
+// syntaxErrorResult 语法错误的结构化表示
+type syntaxErrorResult struct {
+	Valid bool                    `json:"valid"`
+	Error *clickhouse.SyntaxError `json:"error"`
+}
+
+// formatSyntaxError 将语法错误序列化为JSON
+func formatSyntaxError(syntaxErr *clickhouse.SyntaxError) (string, error) {
+	return marshalIndentJSON(syntaxErrorResult{Error: syntaxErr})
+}
+
+// formatValidQuery 以SQL代码块输出检查通过的格式化查询
+func formatValidQuery(formatted string) string {
+	return "查询语法正确，格式化后的查询:\n\n```sql\n" + formatted + "\n```\n"
+}
@@ -0,0 +1,206 @@
+package mcp
+
+import (
+	"testing"
+
+	"clickhouse-mcp/clickhouse"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    OutputFormat
+		wantErr bool
+	}{
+		{name: "По умолчанию", input: "", want: FormatJSON},
+		{name: "json", input: "json", want: FormatJSON},
+		{name: "compact в верхнем регистре", input: "COMPACT", want: FormatCompact},
+		{name: "markdown", input: " markdown ", want: FormatMarkdown},
+		{name: "Неизвестный формат", input: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputFormat(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatResult(t *testing.T) {
+	// Два столбца с одинаковым именем
+	result := clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{
+			{Name: "a", Type: "UInt8", Position: 1},
+			{Name: "a", Type: "String", Position: 2},
+		},
+		Rows: [][]any{{uint8(1), "x"}},
+	}
+
+	tests := []struct {
+		name   string
+		format OutputFormat
+		want   string
+	}{
+		{
+			name:   "json",
+			format: FormatJSON,
+			want: `{
+  "columns": [
+    {
+      "name": "a",
+      "type": "UInt8",
+      "position": 1
+    },
+    {
+      "name": "a",
+      "type": "String",
+      "position": 2
+    }
+  ],
+  "rows": [
+    {
+      "a": "x"
+    }
+  ],
+  "truncated": false
+}`,
+		},
+		{
+			name:   "compact",
+			format: FormatCompact,
+			want: `{"columns":[{"name":"a","type":"UInt8","position":1},{"name":"a","type":"String","position":2}],` +
+				`"rows":[[1,"x"]],"truncated":false}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatResult(result, tt.format, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatResultText(t *testing.T) {
+	// Строки со спецсимволами, NULL и вложенные типы
+	result := clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{
+			{Name: "id", Type: "UInt64", Position: 1},
+			{Name: "text", Type: "Nullable(String)", Position: 2},
+			{Name: "tags", Type: "Map(String, Array(UInt8))", Position: 3},
+		},
+		Rows: [][]any{
+			{uint64(1), "a,\"b\"\tc|d\ne", map[string]any{"<k>": []any{uint64(1), uint64(2)}}},
+			{uint64(2), nil, map[string]any{}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format OutputFormat
+		want   string
+	}{
+		{
+			name:   "jsonl",
+			format: FormatJSONL,
+			want: `{"id":1,"tags":{"<k>":[1,2]},"text":"a,\"b\"\tc|d\ne"}` + "\n" +
+				`{"id":2,"tags":{},"text":null}` + "\n",
+		},
+		{
+			name:   "csv",
+			format: FormatCSV,
+			want: "id,text,tags\n" +
+				"1,\"a,\"\"b\"\"\tc|d\ne\",\"{\"\"<k>\"\":[1,2]}\"\n" +
+				"2,\\N,{}\n",
+		},
+		{
+			name:   "tsv",
+			format: FormatTSV,
+			want: "id\ttext\ttags\n" +
+				"1\ta,\"b\"\\tc|d\\ne\t{\"<k>\":[1,2]}\n" +
+				"2\t\\N\t{}\n",
+		},
+		{
+			name:   "markdown",
+			format: FormatMarkdown,
+			want: "| id | text | tags |\n" +
+				"| --- | --- | --- |\n" +
+				"| 1 | a,\"b\"\tc\\|d<br>e | {\"<k>\":[1,2]} |\n" +
+				"| 2 | NULL | {} |\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatResult(result, tt.format, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFormatMetadata(t *testing.T) {
+	result := clickhouse.QueryResult{
+		Columns:   []clickhouse.ColumnInfo{{Name: "n", Type: "UInt8", Position: 1}},
+		Rows:      [][]any{{uint8(1)}, {uint8(2)}},
+		Truncated: true,
+	}
+
+	got, err := formatMetadata(result, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `{"columns":[{"name":"n","type":"UInt8","position":1}],"rows":2,"truncated":true}`, got)
+}
+
+func TestFormatResultHTMLCharacters(t *testing.T) {
+	// Символы <, > и & не экранируются ни в одном формате, иначе строка увеличивается и расходует бюджет
+	result := clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "html", Type: "String", Position: 1}},
+		Rows:    [][]any{{"<a href='x'>b & c</a>"}},
+	}
+	for _, format := range []OutputFormat{FormatJSON, FormatCompact, FormatJSONL} {
+		got, err := formatResult(result, format, nil)
+		assert.NoError(t, err)
+		assert.Contains(t, got, "<a href='x'>b & c</a>", format)
+	}
+
+	got, err := formatMetadata(clickhouse.QueryResult{Columns: []clickhouse.ColumnInfo{{Name: "a<b", Type: "String", Position: 1}}}, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, got, `"a<b"`)
+}
+
+func TestFormatResultStats(t *testing.T) {
+	readRows, readBytes := uint64(10), uint64(80)
+	result := clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "n", Type: "UInt8", Position: 1}},
+		Rows:    [][]any{{uint8(1)}},
+		Stats: &clickhouse.QueryStats{
+			QueryID:         "q1",
+			ElapsedMs:       1.5,
+			ReadRows:        &readRows,
+			ReadBytes:       &readBytes,
+			ResultRows:      1,
+			PeakMemoryBytes: 4096,
+		},
+	}
+	stats := `"stats":{"query_id":"q1","elapsed_ms":1.5,"read_rows":10,"read_bytes":80,"result_rows":1,"peak_memory_bytes":4096}`
+
+	got, err := formatResult(result, FormatCompact, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, got, stats)
+
+	// Табличные форматы передают статистику в метаданных
+	got, err = formatMetadata(result, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, got, stats)
+}
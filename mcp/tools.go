@@ -2,8 +2,9 @@ package mcp
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"clickhouse-mcp/clickhouse"
@@ -25,18 +26,115 @@ type ToolHandler interface {
 
 	// HandleQueryTool 处理执行SQL查询请求
 	HandleQueryTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+	// HandleExplainTool 处理查看查询执行计划请求
+	HandleExplainTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+	// HandleValidateQueryTool 处理检查查询语法请求
+	HandleValidateQueryTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+	// HandleListConnectionsTool 处理查看连接列表及其状态请求
+	HandleListConnectionsTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+}
+
+// Connections 按名称提供ClickHouse客户端的命名连接，见clickhouse.Registry
+type Connections interface {
+	// Client 返回名为name的连接的客户端，name为空时返回默认连接的客户端
+	Client(ctx context.Context, name string) (clickhouse.Client, error)
+
+	// Status 检查所有连接，返回其健康状态和服务端版本
+	Status(ctx context.Context) []clickhouse.ConnectionStatus
+}
+
+// ToolConfig 包含工具处理器配置
+type ToolConfig struct {
+	// AllowedStatements query和explain工具允许的语句类别，为空时只允许只读语句
+	AllowedStatements []clickhouse.StatementKind
+	// NumericMode query工具默认的大整数和Decimal编码方式，可被numeric参数覆盖
+	NumericMode clickhouse.NumericMode
+	// DefaultFormat query工具默认的输出格式，可被format参数覆盖
+	DefaultFormat OutputFormat
+	// MaxRows query工具最多返回的行数，limit参数不能超过该值，0表示不限制
+	MaxRows int
+	// MaxResultBytes query工具结果行按JSON编码后的最大字节数，超出后停止读取并取消查询，0表示不限制
+	MaxResultBytes int
+	// Settings query工具允许调用方通过settings参数修改的查询设置及其上限，为空时不允许修改
+	Settings clickhouse.SettingsPolicy
+	// ResponseBudget query、explain、get_tables和get_schema工具响应的大小预算，max_bytes和max_tokens参数只能收紧该预算
+	ResponseBudget ResponseBudget
+	// ValidateQueries query工具执行前先由服务端解析查询，语法错误时返回错误位置而不执行
+	ValidateQueries bool
+	// StackTraces 在ClickHouse错误中返回服务端调用栈
+	StackTraces bool
 }
 
 // DefaultToolHandler 默认工具处理器实现
 type DefaultToolHandler struct {
-	client clickhouse.Client
+	connections Connections
+	config      ToolConfig
 }
 
-// NewToolHandler 创建新的工具处理器实例
-func NewToolHandler(client clickhouse.Client) ToolHandler {
+// NewToolHandler 创建新的工具处理器实例，各工具使用connection参数指定的连接
+func NewToolHandler(connections Connections, config ToolConfig) ToolHandler {
+	if len(config.AllowedStatements) == 0 {
+		config.AllowedStatements = []clickhouse.StatementKind{clickhouse.StatementRead}
+	}
+	if config.NumericMode == "" {
+		config.NumericMode = clickhouse.NumericNumber
+	}
+	if config.DefaultFormat == "" {
+		config.DefaultFormat = FormatJSON
+	}
 	return &DefaultToolHandler{
-		client: client,
+		connections: connections,
+		config:      config,
+	}
+}
+
+// client 返回connection参数指定的连接的客户端，未指定时返回默认连接的客户端
+func (h *DefaultToolHandler) client(ctx context.Context, arguments map[string]interface{}) (clickhouse.Client, *mcp.CallToolResult) {
+	var name string
+	if val, ok := arguments["connection"]; ok && val != nil {
+		if name, ok = val.(string); !ok {
+			return nil, mcp.NewToolResultError("无效的'connection'参数: 必须是字符串")
+		}
+	}
+	client, err := h.connections.Client(ctx, name)
+	if err != nil {
+		return nil, h.toolError("获取连接错误", err)
 	}
+	return client, nil
+}
+
+// checkStatements 检查查询中的每条语句是否属于允许的类别
+func (h *DefaultToolHandler) checkStatements(query string) error {
+	statements := clickhouse.ClassifyStatements(query)
+	if len(statements) == 0 {
+		return fmt.Errorf("查询为空")
+	}
+
+	for _, stmt := range statements {
+		if !slices.Contains(h.config.AllowedStatements, stmt.Kind) {
+			keyword := stmt.Keyword
+			if keyword == "" {
+				keyword = "未识别的"
+			}
+			return fmt.Errorf("不允许执行%s语句(类别: %s)", keyword, stmt.Kind)
+		}
+	}
+	return nil
+}
+
+// responseBudget 返回本次调用生效的响应预算: 服务器预算与max_bytes、max_tokens参数中较严格的一个
+func (h *DefaultToolHandler) responseBudget(arguments map[string]interface{}) ResponseBudget {
+	var call ResponseBudget
+	if maxBytes, ok := arguments["max_bytes"].(float64); ok {
+		call.MaxBytes = int(maxBytes)
+	}
+	if maxTokens, ok := arguments["max_tokens"].(float64); ok {
+		call.MaxTokens = int(maxTokens)
+	}
+	return h.config.ResponseBudget.Tighten(call)
 }
 
 // HandleGetDatabasesTool обрабатывает запрос на получение списка баз данных
@@ -44,9 +142,14 @@ func (h *DefaultToolHandler) HandleGetDatabasesTool(
 	ctx context.Context,
 	request mcp.CallToolRequest,
 ) (*mcp.CallToolResult, error) {
-	databases, err := h.client.GetDatabases(ctx)
+	client, errResult := h.client(ctx, request.Params.Arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	databases, err := client.GetDatabases(ctx)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("获取数据库错误: %s", err)), nil
+		return h.toolError("获取数据库错误", err), nil
 	}
 
 	// Форматируем результат в текстовый вид
@@ -69,24 +172,32 @@ func (h *DefaultToolHandler) HandleGetTablesTool(
 	if !ok {
 		return mcp.NewToolResultError("必须指定'database'参数"), nil
 	}
+	if err := clickhouse.ValidateIdentifier(database); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("无效的数据库名称%q: %s", database, err)), nil
+	}
 
-	tables, err := h.client.GetTables(ctx, database)
+	client, errResult := h.client(ctx, arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+	tables, err := client.GetTables(ctx, database)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("获取表错误: %s", err)), nil
+		return h.toolError("获取表错误", err), nil
 	}
 
 	// Форматируем результат в текстовый вид
-	result := fmt.Sprintf("数据库'%s'中的表:\n\n", database)
+	header := fmt.Sprintf("数据库'%s'中的表:\n\n", database)
 	if len(tables) == 0 {
-		result += "未找到表"
-	} else {
-		for i, table := range tables {
-			result += fmt.Sprintf("%d. %s\n", i+1, table)
-		}
+		return mcp.NewToolResultText(header + "未找到表"), nil
+	}
+	items := make([]string, len(tables))
+	for i, table := range tables {
+		items[i] = fmt.Sprintf("%d. %s\n", i+1, table)
 	}
 
-	// Возвращаем результат
-	return mcp.NewToolResultText(result), nil
+	// Возвращаем результат, отбрасывая таблицы сверх бюджета ответа
+	limit := h.responseBudget(arguments).Limit()
+	return mcp.NewToolResultText(fitList(header, items, limit, "表")), nil
 }
 
 // HandleGetTableSchemaTool обрабатывает запрос на получение схемы таблицы
@@ -101,26 +212,37 @@ func (h *DefaultToolHandler) HandleGetTableSchemaTool(
 	if !ok1 || !ok2 {
 		return mcp.NewToolResultError("必须指定'database'和'table'参数"), nil
 	}
+	if err := clickhouse.ValidateIdentifier(database); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("无效的数据库名称%q: %s", database, err)), nil
+	}
+	if err := clickhouse.ValidateIdentifier(table); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("无效的表名称%q: %s", table, err)), nil
+	}
 
-	columns, err := h.client.GetTableSchema(ctx, database, table)
+	client, errResult := h.client(ctx, arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+	columns, err := client.GetTableSchema(ctx, database, table)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("获取表结构错误: %s", err)), nil
+		return h.toolError("获取表结构错误", err), nil
 	}
 
 	// Форматируем результат в текстовый вид
-	result := fmt.Sprintf("表'%s.%s'结构:\n\n", database, table)
+	header := fmt.Sprintf("表'%s.%s'结构:\n\n", database, table)
 	if len(columns) == 0 {
-		result += "未找到列"
-	} else {
-		result += fmt.Sprintf("%-20s | %-30s | %s\n", "列名", "类型", "位置")
-		result += strings.Repeat("-", 70) + "\n"
-		for _, col := range columns {
-			result += fmt.Sprintf("%-20s | %-30s | %d\n", col.Name, col.Type, col.Position)
-		}
+		return mcp.NewToolResultText(header + "未找到列"), nil
+	}
+	header += fmt.Sprintf("%-20s | %-30s | %s\n", "列名", "类型", "位置")
+	header += strings.Repeat("-", 70) + "\n"
+	items := make([]string, len(columns))
+	for i, col := range columns {
+		items[i] = fmt.Sprintf("%-20s | %-30s | %d\n", col.Name, col.Type, col.Position)
 	}
 
-	// Возвращаем результат
-	return mcp.NewToolResultText(result), nil
+	// Возвращаем результат, отбрасывая столбцы сверх бюджета ответа
+	limit := h.responseBudget(arguments).Limit()
+	return mcp.NewToolResultText(fitList(header, items, limit, "列")), nil
 }
 
 // HandleQueryTool обрабатывает запрос на выполнение SQL запроса
@@ -136,37 +258,245 @@ func (h *DefaultToolHandler) HandleQueryTool(
 		return mcp.NewToolResultError("必须指定'query'参数"), nil
 	}
 
+	// Проверяем, что запрос содержит только разрешённые выражения
+	if err := h.checkStatements(query); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("查询被拒绝: %s", err)), nil
+	}
+
 	// Извлекаем лимит, если он задан
 	if limitVal, ok := arguments["limit"].(float64); ok {
 		limit = int(limitVal)
 	}
 
-	// Выполняем запрос
-	results, err := h.client.QueryData(ctx, query, limit)
+	// Лимит не может превышать ограничение сервера
+	if h.config.MaxRows > 0 && (limit <= 0 || limit > h.config.MaxRows) {
+		limit = h.config.MaxRows
+	}
+
+	// Режим кодирования больших чисел: аргумент вызова или настройка сервера
+	numeric := h.config.NumericMode
+	if numericVal, ok := arguments["numeric"].(string); ok {
+		mode, err := clickhouse.ParseNumericMode(numericVal)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("无效的'numeric'参数: %s", err)), nil
+		}
+		numeric = mode
+	}
+
+	// Формат вывода: аргумент вызова или настройка сервера
+	format := h.config.DefaultFormat
+	if formatVal, ok := arguments["format"].(string); ok && formatVal != "" {
+		parsed, err := ParseOutputFormat(formatVal)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("无效的'format'参数: %s", err)), nil
+		}
+		format = parsed
+	}
+
+	// Часовой пояс для значений DateTime, по умолчанию сохраняется пояс столбца
+	timezone, _ := arguments["timezone"].(string)
+
+	// Значения параметров для плейсхолдеров {name:Type}
+	var params map[string]any
+	if paramsVal, ok := arguments["params"]; ok && paramsVal != nil {
+		params, ok = paramsVal.(map[string]interface{})
+		if !ok {
+			return mcp.NewToolResultError("无效的'params'参数: 必须是对象"), nil
+		}
+	}
+
+	// Настройки выполнения запроса проверяются по списку, заданному оператором
+	var settings map[string]any
+	if settingsVal, ok := arguments["settings"]; ok && settingsVal != nil {
+		requested, ok := settingsVal.(map[string]interface{})
+		if !ok {
+			return mcp.NewToolResultError("无效的'settings'参数: 必须是对象"), nil
+		}
+		validated, err := h.config.Settings.Validate(requested)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("无效的'settings'参数: %s", err)), nil
+		}
+		settings = validated
+	}
+
+	// Подключение, выбранное аргументом connection, или подключение по умолчанию
+	client, errResult := h.client(ctx, arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	// Проверяем синтаксис до выполнения, чтобы вернуть точное место ошибки
+	if h.config.ValidateQueries {
+		if _, err := client.ValidateQuery(ctx, query); err != nil {
+			return h.toolError("查询未执行，检查语法失败", err), nil
+		}
+	}
+
+	// Бюджет ответа: настройка сервера, сужаемая аргументами max_bytes и max_tokens
+	responseLimit := h.responseBudget(arguments).Limit()
+
+	// Выполняем запрос, читая строки потоком в пределах бюджета по размеру
+	budget := &rowBudget{
+		maxBytes:     minLimit(h.config.MaxResultBytes, responseLimit),
+		maxCellBytes: cellLimit(responseLimit),
+	}
+	results, err := client.QueryStream(ctx, query, clickhouse.QueryOptions{
+		Limit:    limit,
+		Numeric:  numeric,
+		Timezone: timezone,
+		Settings: settings,
+		Params:   params,
+		// По HTTP драйвер получает ответ целиком, поэтому сервер сам останавливается по бюджету
+		MaxResultBytes: budget.maxBytes,
+	}, budget.add)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("执行查询错误: %s", err)), nil
+		return h.toolError("执行查询错误", err), nil
 	}
+	results.Rows = budget.rows
 
 	// Прекращаем дальнейшую обработку, если нет колонок
 	if len(results.Columns) == 0 {
 		return mcp.NewToolResultText("查询已执行，无结果"), nil
 	}
 
-	// Преобразуем результаты для JSON
-	jsonBytes, err := json.MarshalIndent(results, "", "  ")
+	// Форматируем результат в выбранном формате, укладываясь в бюджет ответа.
+	// Табличные форматы не содержат типов столбцов и признака обрезки, они передаются отдельно
+	omitted := &omission{
+		BudgetBytes: responseLimit,
+		MoreRows:    budget.exceeded,
+		Cells:       budget.cells,
+		CellBytes:   budget.cellBytes,
+	}
+	parts, err := fitResult(results, format, responseLimit, omitted)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("格式化结果错误: %s", err)), nil
 	}
 
 	// Возвращаем результат в текстовом виде (поскольку mcp-go не имеет метода NewToolResultJSON)
-	return mcp.NewToolResultText(string(jsonBytes)), nil
+	result := mcp.NewToolResultText(parts[0])
+	for _, part := range parts[1:] {
+		result.Content = append(result.Content, mcp.NewTextContent(part))
+	}
+	return result, nil
+}
+
+// HandleExplainTool обрабатывает запрос на получение плана выполнения запроса без его запуска
+func (h *DefaultToolHandler) HandleExplainTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+	query, ok := arguments["query"].(string)
+	if !ok {
+		return mcp.NewToolResultError("必须指定'query'参数"), nil
+	}
+
+	// Анализ запроса может обращаться к табличным функциям, поэтому действуют те же ограничения, что и для query
+	if err := h.checkStatements(query); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("查询被拒绝: %s", err)), nil
+	}
+
+	// Вид EXPLAIN, по умолчанию PLAN
+	kindVal, _ := arguments["kind"].(string)
+	kind, err := clickhouse.ParseExplainKind(kindVal)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("无效的'kind'参数: %s", err)), nil
+	}
+
+	// Параметры EXPLAIN (indexes, actions, graph и т.д.) и значения плейсхолдеров
+	opts := clickhouse.ExplainOptions{Kind: kind}
+	for name, target := range map[string]*map[string]any{"options": &opts.Settings, "params": &opts.Params} {
+		if val, ok := arguments[name]; ok && val != nil {
+			object, ok := val.(map[string]interface{})
+			if !ok {
+				return mcp.NewToolResultError(fmt.Sprintf("无效的'%s'参数: 必须是对象", name)), nil
+			}
+			*target = object
+		}
+	}
+
+	client, errResult := h.client(ctx, arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+	result, err := client.Explain(ctx, query, opts)
+	if err != nil {
+		return h.toolError("执行EXPLAIN错误", err), nil
+	}
+
+	// Форматируем результат блоком кода, отбрасывая строки сверх бюджета ответа
+	header, lines, footer, err := formatExplain(kind, opts.Settings, result)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("格式化结果错误: %s", err)), nil
+	}
+	limit := h.responseBudget(arguments).Limit()
+	if limit > 0 {
+		limit = max(limit-len(footer), 1)
+	}
+	return mcp.NewToolResultText(fitList(header, lines, limit, "行") + footer), nil
+}
+
+// HandleValidateQueryTool обрабатывает запрос на проверку синтаксиса без выполнения запроса
+func (h *DefaultToolHandler) HandleValidateQueryTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	arguments := request.Params.Arguments
+	query, ok := arguments["query"].(string)
+	if !ok {
+		return mcp.NewToolResultError("必须指定'query'参数"), nil
+	}
+
+	client, errResult := h.client(ctx, arguments)
+	if errResult != nil {
+		return errResult, nil
+	}
+	formatted, err := client.ValidateQuery(ctx, query)
+	var syntaxErr *clickhouse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// Синтаксическая ошибка - ожидаемый результат проверки, а не ошибка инструмента
+		text, err := formatSyntaxError(syntaxErr)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("格式化结果错误: %s", err)), nil
+		}
+		return mcp.NewToolResultText(text), nil
+	}
+	if err != nil {
+		return h.toolError("检查查询错误", err), nil
+	}
+	return mcp.NewToolResultText(formatValidQuery(formatted)), nil
 }
 
+// HandleListConnectionsTool обрабатывает запрос на получение списка подключений с их состоянием
+func (h *DefaultToolHandler) HandleListConnectionsTool(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	// Проверяем все подключения; недоступные подключения - часть результата, а не ошибка инструмента
+	text, err := marshalIndentJSON(struct {
+		Connections []clickhouse.ConnectionStatus `json:"connections"`
+	}{h.connections.Status(ctx)})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("格式化结果错误: %s", err)), nil
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// Описания аргументов бюджета ответа, общие для нескольких инструментов
+const (
+	maxBytesDescription  = "响应的最大字节数，只能收紧服务器的预算；超出时截断长字符串(包括数组、Map和Tuple中的字符串)、省略末尾的行、列或条目，并说明省略的内容(query结果中为omitted字段)"
+	maxTokensDescription = "响应的最大token数(按每token约4字节估算)，与max_bytes同时指定时取较严格的一个"
+)
+
+// connectionDescription connection参数的描述，所有访问ClickHouse的工具共用
+const connectionDescription = "使用的ClickHouse连接名称，可用的连接见list_connections工具；不指定时使用默认连接"
+
 // RegisterTools регистрирует инструменты MCP
 func RegisterTools(mcpServer *server.MCPServer, handler ToolHandler) {
 	// Инструмент для получения списка баз данных
 	mcpServer.AddTool(mcp.NewTool("get_databases",
 		mcp.WithDescription("获取ClickHouse数据库列表"),
+		mcp.WithString("connection", mcp.Description(connectionDescription)),
 	), handler.HandleGetDatabasesTool)
 
 	// Инструмент для получения списка таблиц
@@ -176,6 +506,9 @@ func RegisterTools(mcpServer *server.MCPServer, handler ToolHandler) {
 			mcp.Description("数据库名称"),
 			mcp.Required(),
 		),
+		mcp.WithNumber("max_bytes", mcp.Description(maxBytesDescription)),
+		mcp.WithNumber("max_tokens", mcp.Description(maxTokensDescription)),
+		mcp.WithString("connection", mcp.Description(connectionDescription)),
 	), handler.HandleGetTablesTool)
 
 	// Инструмент для получения схемы таблицы
@@ -188,6 +521,9 @@ func RegisterTools(mcpServer *server.MCPServer, handler ToolHandler) {
 			mcp.Description("表名称"),
 			mcp.Required(),
 		),
+		mcp.WithNumber("max_bytes", mcp.Description(maxBytesDescription)),
+		mcp.WithNumber("max_tokens", mcp.Description(maxTokensDescription)),
+		mcp.WithString("connection", mcp.Description(connectionDescription)),
 	), handler.HandleGetTableSchemaTool)
 
 	// Инструмент для выполнения SQL запроса
@@ -197,7 +533,72 @@ func RegisterTools(mcpServer *server.MCPServer, handler ToolHandler) {
 			mcp.Description("要执行的SQL查询"),
 		),
 		mcp.WithNumber("limit",
-			mcp.Description("最大返回行数(默认100)"),
+			mcp.Description("最大返回行数(默认100，不超过服务器上限)，超出行数或结果大小上限时结果中truncated为true"),
+		),
+		mcp.WithString("numeric",
+			mcp.Description("64位及更宽的整数和Decimal的编码方式: number为JSON数字，string为字符串(不丢失精度，列信息中numbers_as_strings为true)"),
+			mcp.Enum(string(clickhouse.NumericNumber), string(clickhouse.NumericString)),
+		),
+		mcp.WithString("format",
+			mcp.Description("结果格式: json(每行一个对象)、compact(columns加按列顺序排列的rows数组，保留同名列，更节省token)、"+
+				"jsonl、csv、tsv或markdown；后四种格式的列类型和truncated在单独的元数据中返回，嵌套类型输出为JSON。默认由服务器配置决定"),
+			mcp.Enum(formatNames()...),
+		),
+		mcp.WithString("timezone",
+			mcp.Description("DateTime值统一转换到的时区: UTC、session(服务端会话时区)或IANA时区名；不指定时保留各列的时区"),
 		),
+		mcp.WithObject("params",
+			mcp.Description("绑定到查询中{名称:类型}占位符的参数值，例如查询\"SELECT * FROM t WHERE id = {id:UInt64} AND name = {name:String}\"配合{\"id\": 42, \"name\": \"a'b\"}。"+
+				"值由服务端按类型解析，不拼接进SQL；数组、Map和Tuple分别使用JSON数组、对象和数组，大整数和Decimal可以用字符串传递"),
+		),
+		mcp.WithObject("settings",
+			mcp.Description("本次查询的ClickHouse设置，例如{\"max_execution_time\": 30, \"max_threads\": 4, \"use_query_cache\": true}。"+
+				"可修改的设置及其上限由服务器配置决定: max_execution_time、max_memory_usage、max_rows_to_read、max_bytes_to_read、max_threads、use_query_cache"),
+		),
+		mcp.WithNumber("max_bytes", mcp.Description(maxBytesDescription)),
+		mcp.WithNumber("max_tokens", mcp.Description(maxTokensDescription)),
+		mcp.WithString("connection", mcp.Description(connectionDescription)),
 	), handler.HandleQueryTool)
+
+	// Инструмент для просмотра плана выполнения запроса
+	mcpServer.AddTool(mcp.NewTool("explain",
+		mcp.WithDescription("查看ClickHouse将如何执行查询(EXPLAIN)，查询本身不会执行"),
+		mcp.WithString("query",
+			mcp.Description("要分析的SQL查询，不含EXPLAIN关键字"),
+			mcp.Required(),
+		),
+		mcp.WithString("kind",
+			mcp.Description("EXPLAIN类别: AST(语法树)、SYNTAX(优化后的查询)、QUERY TREE(分析器查询树)、PLAN(查询计划，默认)、"+
+				"PIPELINE(执行管道)、ESTIMATE(按表估算读取的数据片段、行和标记数)或TABLE OVERRIDE"),
+			mcp.Enum(clickhouse.ExplainKindNames()...),
+		),
+		mcp.WithObject("options",
+			mcp.Description("EXPLAIN选项，值为布尔值或整数。AST: graph、optimize；SYNTAX: oneline、run_query_tree_passes、query_tree_passes；"+
+				"QUERY TREE: run_passes、dump_passes、passes、dump_tree、dump_ast；"+
+				"PLAN: header、description、indexes、projections、actions、json、sorting、distributed；PIPELINE: header、graph、compact。"+
+				"例如PLAN的{\"indexes\": true}显示索引和分区裁剪情况，PIPELINE或AST的{\"graph\": true}输出DOT格式的图"),
+		),
+		mcp.WithObject("params",
+			mcp.Description("绑定到查询中{名称:类型}占位符的参数值，与query工具相同"),
+		),
+		mcp.WithNumber("max_bytes", mcp.Description(maxBytesDescription)),
+		mcp.WithNumber("max_tokens", mcp.Description(maxTokensDescription)),
+		mcp.WithString("connection", mcp.Description(connectionDescription)),
+	), handler.HandleExplainTool)
+
+	// Инструмент для проверки синтаксиса запроса
+	mcpServer.AddTool(mcp.NewTool("validate_query",
+		mcp.WithDescription("由ClickHouse解析查询但不执行，返回格式化后的SQL，或包含行、列和错误码的语法错误"),
+		mcp.WithString("query",
+			mcp.Description("要检查的SQL查询，可以包含多条以分号分隔的语句"),
+			mcp.Required(),
+		),
+		mcp.WithString("connection", mcp.Description(connectionDescription)),
+	), handler.HandleValidateQueryTool)
+
+	// Инструмент для просмотра подключений
+	mcpServer.AddTool(mcp.NewTool("list_connections",
+		mcp.WithDescription("列出配置的ClickHouse连接及其健康状态、延迟、服务端版本和时区，default为true的是默认连接。"+
+			"其他工具的connection参数可以使用这里的名称"),
+	), handler.HandleListConnectionsTool)
 }
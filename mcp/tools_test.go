@@ -2,6 +2,10 @@ package mcp
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"clickhouse-mcp/clickhouse"
@@ -36,11 +40,47 @@ func (m *MockClickhouseClient) GetTableSchema(ctx context.Context, database, tab
 }
 
 // QueryData - мок метод
-func (m *MockClickhouseClient) QueryData(ctx context.Context, query string, limit int) (clickhouse.QueryResult, error) {
-	args := m.Called(ctx, query, limit)
+func (m *MockClickhouseClient) QueryData(ctx context.Context, query string, opts clickhouse.QueryOptions) (clickhouse.QueryResult, error) {
+	args := m.Called(ctx, query, opts)
 	return args.Get(0).(clickhouse.QueryResult), args.Error(1)
 }
 
+// QueryDataWithParams - мок метод
+func (m *MockClickhouseClient) QueryDataWithParams(ctx context.Context, query string, params map[string]any, opts clickhouse.QueryOptions) (clickhouse.QueryResult, error) {
+	args := m.Called(ctx, query, params, opts)
+	return args.Get(0).(clickhouse.QueryResult), args.Error(1)
+}
+
+// Explain - мок метод
+func (m *MockClickhouseClient) Explain(ctx context.Context, query string, opts clickhouse.ExplainOptions) (clickhouse.QueryResult, error) {
+	args := m.Called(ctx, query, opts)
+	return args.Get(0).(clickhouse.QueryResult), args.Error(1)
+}
+
+// ValidateQuery - мок метод
+func (m *MockClickhouseClient) ValidateQuery(ctx context.Context, query string) (string, error) {
+	args := m.Called(ctx, query)
+	return args.String(0), args.Error(1)
+}
+
+// QueryStream - мок метод, передаёт строки из результата в fn
+func (m *MockClickhouseClient) QueryStream(ctx context.Context, query string, opts clickhouse.QueryOptions, fn clickhouse.RowFunc) (clickhouse.QueryResult, error) {
+	args := m.Called(ctx, query, opts)
+	result := args.Get(0).(clickhouse.QueryResult)
+	rows := result.Rows
+	result.Rows = nil
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			if errors.Is(err, clickhouse.ErrStopQuery) {
+				result.Truncated = true
+				break
+			}
+			return clickhouse.QueryResult{}, err
+		}
+	}
+	return result, args.Error(1)
+}
+
 // GetConnection - мок метод
 func (m *MockClickhouseClient) GetConnection() driver.Conn {
 	args := m.Called()
@@ -53,6 +93,34 @@ func (m *MockClickhouseClient) Close() error {
 	return args.Error(0)
 }
 
+// mockConnections - тестовые именованные подключения, пустое имя означает подключение "default"
+type mockConnections struct {
+	clients  map[string]clickhouse.Client
+	statuses []clickhouse.ConnectionStatus
+}
+
+// singleConnection - подключения из одного клиента по умолчанию
+func singleConnection(client clickhouse.Client) *mockConnections {
+	return &mockConnections{clients: map[string]clickhouse.Client{"default": client}}
+}
+
+// Client - возвращает клиента по имени подключения
+func (c *mockConnections) Client(_ context.Context, name string) (clickhouse.Client, error) {
+	if name == "" {
+		name = "default"
+	}
+	client, ok := c.clients[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的连接%q", name)
+	}
+	return client, nil
+}
+
+// Status - возвращает заданные состояния подключений
+func (c *mockConnections) Status(context.Context) []clickhouse.ConnectionStatus {
+	return c.statuses
+}
+
 // getText - вспомогательная функция для извлечения текста из результата инструмента
 func getText(result *mcp.CallToolResult) string {
 	if len(result.Content) == 0 {
@@ -76,7 +144,7 @@ func TestHandleGetDatabasesTool(t *testing.T) {
 	mockClient.On("GetDatabases", mock.Anything).Return([]string{"db1", "db2", "db3"}, nil)
 
 	// Создаем тестируемый обработчик
-	handler := NewToolHandler(mockClient)
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
 
 	// Создаем тестовый запрос
 	request := mcp.CallToolRequest{}
@@ -106,7 +174,7 @@ func TestHandleGetTablesTool(t *testing.T) {
 	mockClient.On("GetTables", mock.Anything, "test_db").Return([]string{"table1", "table2"}, nil)
 
 	// Создаем тестируемый обработчик
-	handler := NewToolHandler(mockClient)
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
 
 	// Тест 1: корректный запрос
 	t.Run("Valid Request", func(t *testing.T) {
@@ -143,7 +211,22 @@ func TestHandleGetTablesTool(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.True(t, result.IsError)
 		text := getText(result)
-		assert.Contains(t, text, "Необходимо указать параметр 'database'")
+		assert.Contains(t, text, "必须指定'database'参数")
+	})
+
+	// Тест 3: некорректное имя базы данных
+	t.Run("Invalid Database Name", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "get_tables"
+		request.Params.Arguments = map[string]interface{}{
+			"database": "db\nname",
+		}
+
+		result, err := handler.HandleGetTablesTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "无效的数据库名称")
 	})
 
 	// Проверяем, что все ожидаемые методы были вызваны
@@ -162,7 +245,7 @@ func TestHandleGetTableSchemaTool(t *testing.T) {
 	}, nil)
 
 	// Создаем тестируемый обработчик
-	handler := NewToolHandler(mockClient)
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
 
 	// Тест 1: корректный запрос
 	t.Run("Valid Request", func(t *testing.T) {
@@ -202,7 +285,23 @@ func TestHandleGetTableSchemaTool(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.True(t, result.IsError)
 		text := getText(result)
-		assert.Contains(t, text, "Необходимо указать параметры 'database' и 'table'")
+		assert.Contains(t, text, "必须指定'database'和'table'参数")
+	})
+
+	// Тест 3: некорректное имя таблицы
+	t.Run("Invalid Table Name", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "get_schema"
+		request.Params.Arguments = map[string]interface{}{
+			"database": "test_db",
+			"table":    "",
+		}
+
+		result, err := handler.HandleGetTableSchemaTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "无效的表名称")
 	})
 
 	// Проверяем, что все ожидаемые методы были вызваны
@@ -214,17 +313,20 @@ func TestHandleQueryTool(t *testing.T) {
 	mockClient := new(MockClickhouseClient)
 
 	// Устанавливаем ожидаемое поведение для запроса
-	mockClient.On("QueryData", mock.Anything, "SELECT 1 as test", 10).Return(clickhouse.QueryResult{
+	mockClient.On("QueryStream", mock.Anything, "SELECT 1 as test", clickhouse.QueryOptions{
+		Limit:   10,
+		Numeric: clickhouse.NumericNumber,
+	}).Return(clickhouse.QueryResult{
 		Columns: []clickhouse.ColumnInfo{
 			{Name: "test", Type: "UInt8", Position: 1},
 		},
-		Rows: []map[string]interface{}{
-			{"test": uint8(1)},
+		Rows: [][]interface{}{
+			{uint8(1)},
 		},
 	}, nil)
 
 	// Создаем тестируемый обработчик
-	handler := NewToolHandler(mockClient)
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
 
 	// Тест 1: корректный запрос с указанным лимитом
 	t.Run("Valid Request With Limit", func(t *testing.T) {
@@ -245,9 +347,43 @@ func TestHandleQueryTool(t *testing.T) {
 		text := getText(result)
 		assert.Contains(t, text, "test")
 		assert.Contains(t, text, "UInt8")
+		assert.Contains(t, text, `"truncated": false`)
+	})
+
+	// Тест 2: компактный формат сохраняет порядок столбцов
+	t.Run("Compact Format", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "query"
+		request.Params.Arguments = map[string]interface{}{
+			"query":  "SELECT 1 as test",
+			"limit":  float64(10),
+			"format": "compact",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Contains(t, getText(result), `"rows":[[1]]`)
+	})
+
+	// Тест 3: неизвестный формат
+	t.Run("Unknown Format", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "query"
+		request.Params.Arguments = map[string]interface{}{
+			"query":  "SELECT 1 as test",
+			"format": "xml",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "format")
 	})
 
-	// Тест 2: отсутствует обязательный параметр query
+	// Тест 4: отсутствует обязательный параметр query
 	t.Run("Missing Required Parameter", func(t *testing.T) {
 		// Создаем тестовый запрос без обязательного параметра
 		request := mcp.CallToolRequest{}
@@ -264,9 +400,725 @@ func TestHandleQueryTool(t *testing.T) {
 		assert.NotNil(t, result)
 		assert.True(t, result.IsError)
 		text := getText(result)
-		assert.Contains(t, text, "Необходимо указать параметр 'query'")
+		assert.Contains(t, text, "必须指定'query'参数")
+	})
+
+	// Тест 5: запрещённое выражение не доходит до клиента
+	t.Run("Rejected Statement", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "query"
+		request.Params.Arguments = map[string]interface{}{
+			"query": "SELECT 1; DROP TABLE test",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		text := getText(result)
+		assert.Contains(t, text, "DROP")
+		assert.Contains(t, text, "ddl")
 	})
 
 	// Проверяем, что все ожидаемые методы были вызваны
 	mockClient.AssertExpectations(t)
 }
+
+func TestHandleQueryToolAllowedStatements(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "ALTER TABLE t DELETE WHERE 1", clickhouse.QueryOptions{
+		Limit:   100,
+		Numeric: clickhouse.NumericNumber,
+	}).Return(clickhouse.QueryResult{}, nil)
+
+	// Разрешаем изменение данных, но не изменение схемы
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{
+		AllowedStatements: []clickhouse.StatementKind{clickhouse.StatementRead, clickhouse.StatementDML},
+	})
+
+	t.Run("Allowed DML", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "ALTER TABLE t DELETE WHERE 1",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+	})
+
+	t.Run("Rejected DDL", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "ALTER TABLE t DROP COLUMN x",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+	})
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleQueryToolNumericMode(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "SELECT id FROM t", clickhouse.QueryOptions{
+		Limit:   100,
+		Numeric: clickhouse.NumericString,
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{
+			{Name: "id", Type: "UInt64", Position: 1, NumbersAsStrings: true},
+		},
+		Rows: [][]interface{}{
+			{"18446744073709551615"},
+		},
+	}, nil)
+	mockClient.On("QueryStream", mock.Anything, "SELECT id FROM t", clickhouse.QueryOptions{
+		Limit:   100,
+		Numeric: clickhouse.NumericNumber,
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "id", Type: "UInt64", Position: 1}},
+		Rows:    [][]interface{}{{uint64(1)}},
+	}, nil)
+
+	// По умолчанию сервер кодирует большие числа строками
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{NumericMode: clickhouse.NumericString})
+
+	t.Run("Режим сервера по умолчанию", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "SELECT id FROM t",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		text := getText(result)
+		assert.Contains(t, text, `"18446744073709551615"`)
+		assert.Contains(t, text, `"numbers_as_strings": true`)
+	})
+
+	t.Run("Режим из аргумента", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":   "SELECT id FROM t",
+			"numeric": "number",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.NotContains(t, getText(result), "numbers_as_strings")
+	})
+
+	t.Run("Неизвестный режим", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":   "SELECT id FROM t",
+			"numeric": "float",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "numeric")
+	})
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleQueryToolTimezone(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "SELECT now64()", clickhouse.QueryOptions{
+		Limit:    100,
+		Numeric:  clickhouse.NumericNumber,
+		Timezone: "UTC",
+	}).Return(clickhouse.QueryResult{
+		Columns:  []clickhouse.ColumnInfo{{Name: "now64()", Type: "DateTime64(3)", Position: 1, Timezone: "UTC"}},
+		Rows:     [][]interface{}{{"2024-01-02T03:04:05.678Z"}},
+		Timezone: "UTC",
+	}, nil)
+
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{
+		"query":    "SELECT now64()",
+		"timezone": "UTC",
+	}
+
+	result, err := handler.HandleQueryTool(context.Background(), request)
+
+	assert.NoError(t, err)
+	assert.False(t, result.IsError)
+	text := getText(result)
+	assert.Contains(t, text, "2024-01-02T03:04:05.678Z")
+	assert.Contains(t, text, `"timezone": "UTC"`)
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleQueryToolParams(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "SELECT * FROM t WHERE id = {id:UInt64}", clickhouse.QueryOptions{
+		Limit:   100,
+		Numeric: clickhouse.NumericNumber,
+		Params:  map[string]any{"id": float64(42)},
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "id", Type: "UInt64", Position: 1}},
+		Rows:    [][]interface{}{{uint64(42)}},
+	}, nil)
+
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
+
+	t.Run("Параметры передаются клиенту", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":  "SELECT * FROM t WHERE id = {id:UInt64}",
+			"params": map[string]interface{}{"id": float64(42)},
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Contains(t, getText(result), `"id": 42`)
+	})
+
+	t.Run("Параметры не объект", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":  "SELECT * FROM t WHERE id = {id:UInt64}",
+			"params": []interface{}{float64(42)},
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "无效的'params'参数")
+	})
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleQueryToolSettings(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "SELECT 1", clickhouse.QueryOptions{
+		Limit:    100,
+		Numeric:  clickhouse.NumericNumber,
+		Settings: map[string]any{"max_execution_time": uint64(10), "use_query_cache": 1},
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "1", Type: "UInt8", Position: 1}},
+		Rows:    [][]interface{}{{uint8(1)}},
+	}, nil)
+
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{
+		Settings: clickhouse.SettingsPolicy{
+			"max_execution_time": {Max: 60},
+			"use_query_cache":    {},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		settings interface{}
+		wantErr  string
+	}{
+		{
+			name:     "Разрешённые настройки",
+			settings: map[string]interface{}{"max_execution_time": float64(10), "use_query_cache": true},
+		},
+		{
+			name:     "Превышение ограничения",
+			settings: map[string]interface{}{"max_execution_time": float64(600)},
+			wantErr:  "超出允许范围1..60",
+		},
+		{
+			name:     "Настройка не разрешена",
+			settings: map[string]interface{}{"max_threads": float64(4)},
+			wantErr:  "不允许修改设置max_threads",
+		},
+		{
+			name:     "Не объект",
+			settings: "max_threads=4",
+			wantErr:  "必须是对象",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := mcp.CallToolRequest{}
+			request.Params.Arguments = map[string]interface{}{
+				"query":    "SELECT 1",
+				"settings": tt.settings,
+			}
+
+			result, err := handler.HandleQueryTool(context.Background(), request)
+
+			assert.NoError(t, err)
+			if tt.wantErr != "" {
+				assert.True(t, result.IsError)
+				assert.Contains(t, getText(result), tt.wantErr)
+				return
+			}
+			assert.False(t, result.IsError)
+		})
+	}
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleQueryToolDefaultFormat(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "SELECT n FROM t", mock.Anything).Return(clickhouse.QueryResult{
+		Columns:   []clickhouse.ColumnInfo{{Name: "n", Type: "UInt8", Position: 1}},
+		Rows:      [][]interface{}{{uint8(1)}},
+		Truncated: true,
+	}, nil)
+
+	// Сервер по умолчанию возвращает CSV
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{DefaultFormat: FormatCSV})
+
+	t.Run("Формат сервера с метаданными", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "SELECT n FROM t",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Len(t, result.Content, 2)
+		assert.Equal(t, "n\n1\n", getText(result))
+		metadata, ok := mcp.AsTextContent(result.Content[1])
+		assert.True(t, ok)
+		assert.Contains(t, metadata.Text, `"truncated":true`)
+		assert.Contains(t, metadata.Text, `"type":"UInt8"`)
+	})
+
+	t.Run("Формат из аргумента", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":  "SELECT n FROM t",
+			"format": "markdown",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "| n |\n| --- |\n| 1 |\n", getText(result))
+	})
+
+	t.Run("JSON без отдельных метаданных", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":  "SELECT n FROM t",
+			"format": "json",
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Content, 1)
+	})
+}
+
+func TestHandleQueryToolBudget(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("QueryStream", mock.Anything, "SELECT s FROM t", clickhouse.QueryOptions{
+		Limit:          50,
+		Numeric:        clickhouse.NumericNumber,
+		MaxResultBytes: 20,
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "s", Type: "String", Position: 1}},
+		Rows:    [][]interface{}{{"aaaa"}, {"bbbb"}, {"cccc"}},
+	}, nil)
+
+	// Не больше 50 строк и 20 байт
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{MaxRows: 50, MaxResultBytes: 20})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{
+		"query":  "SELECT s FROM t",
+		"limit":  float64(1000),
+		"format": "compact",
+	}
+
+	result, err := handler.HandleQueryTool(context.Background(), request)
+
+	assert.NoError(t, err)
+	assert.False(t, result.IsError)
+	text := getText(result)
+	assert.Contains(t, text, `"rows":[["aaaa"],["bbbb"]]`)
+	assert.Contains(t, text, `"truncated":true`)
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleToolsResponseBudget(t *testing.T) {
+	long := strings.Repeat("x", 200)
+
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("GetTables", mock.Anything, "db").Return([]string{"t1_" + long, "t2_" + long, "t3_" + long}, nil)
+	mockClient.On("QueryStream", mock.Anything, "SELECT s FROM t", clickhouse.QueryOptions{
+		Limit:          100,
+		Numeric:        clickhouse.NumericNumber,
+		MaxResultBytes: 1000,
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "s", Type: "String", Position: 1}},
+		Rows:    [][]interface{}{{long}, {"short"}},
+	}, nil)
+
+	// Бюджет сервера 1000 байт, вызов может только уменьшить его
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{ResponseBudget: ResponseBudget{MaxBytes: 1000}})
+
+	t.Run("get_tables отбрасывает таблицы", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"database":  "db",
+			"max_bytes": float64(500),
+		}
+
+		result, err := handler.HandleGetTablesTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		text := getText(result)
+		assert.Contains(t, text, "t1_")
+		assert.NotContains(t, text, "t3_")
+		assert.Contains(t, text, "超出响应预算(500字节)")
+		assert.LessOrEqual(t, len(text), 500)
+	})
+
+	t.Run("query обрезает длинные значения", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":      "SELECT s FROM t",
+			"format":     "compact",
+			"max_tokens": float64(5000),
+		}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		text := getText(result)
+		// Действует бюджет сервера: 1000 байт, значения не длиннее 125 байт
+		assert.Contains(t, text, `"rows":[["`+long[:125]+`…"],["short"]]`)
+		assert.Contains(t, text, `"truncated":true`)
+		assert.Contains(t, text, `"omitted":{"budget_bytes":1000,"cells":1,"cell_bytes":75}`)
+	})
+}
+
+func TestHandleExplainTool(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("Explain", mock.Anything, "SELECT count() FROM t", clickhouse.ExplainOptions{
+		Kind:     clickhouse.ExplainPipeline,
+		Settings: map[string]any{"graph": true},
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "explain", Type: "String", Position: 1}},
+		Rows:    [][]interface{}{{"digraph"}, {"{"}, {"  n1 [label=\"AggregatingTransform\"];"}, {"}"}},
+	}, nil)
+	mockClient.On("Explain", mock.Anything, "SELECT * FROM t", clickhouse.ExplainOptions{
+		Kind: clickhouse.ExplainEstimate,
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{
+			{Name: "database", Type: "String", Position: 1},
+			{Name: "table", Type: "String", Position: 2},
+			{Name: "parts", Type: "UInt64", Position: 3},
+			{Name: "rows", Type: "UInt64", Position: 4},
+			{Name: "marks", Type: "UInt64", Position: 5},
+		},
+		Rows: [][]interface{}{{"default", "t", uint64(3), uint64(1000), uint64(5)}},
+	}, nil)
+	mockClient.On("Explain", mock.Anything, "SELECT 1", clickhouse.ExplainOptions{
+		Kind: clickhouse.ExplainPlan,
+	}).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "explain", Type: "String", Position: 1}},
+		Rows: [][]interface{}{
+			{"Expression ((Projection + Before ORDER BY))"},
+			{"  ReadFromStorage (SystemOne)"},
+		},
+	}, nil)
+
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
+
+	t.Run("Граф конвейера в формате DOT", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":   "SELECT count() FROM t",
+			"kind":    "pipeline",
+			"options": map[string]interface{}{"graph": true},
+		}
+
+		result, err := handler.HandleExplainTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Equal(t, "EXPLAIN PIPELINE结果:\n\n```dot\ndigraph\n{\n  n1 [label=\"AggregatingTransform\"];\n}\n```\n", getText(result))
+	})
+
+	t.Run("ESTIMATE в виде таблицы", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "SELECT * FROM t",
+			"kind":  "ESTIMATE",
+		}
+
+		result, err := handler.HandleExplainTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Contains(t, getText(result), "| database | table | parts | rows | marks |")
+		assert.Contains(t, getText(result), "| default | t | 3 | 1000 | 5 |")
+	})
+
+	t.Run("План с бюджетом ответа", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":     "SELECT 1",
+			"max_bytes": float64(80),
+		}
+
+		result, err := handler.HandleExplainTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		text := getText(result)
+		assert.NotContains(t, text, "ReadFromStorage")
+		assert.Contains(t, text, "超出响应预算")
+		assert.True(t, strings.HasSuffix(text, "```\n"))
+	})
+
+	t.Run("Неизвестный вид", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "SELECT 1",
+			"kind":  "indexes",
+		}
+
+		result, err := handler.HandleExplainTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "无效的'kind'参数")
+	})
+
+	t.Run("Опции не объект", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query":   "SELECT 1",
+			"options": "indexes=1",
+		}
+
+		result, err := handler.HandleExplainTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "无效的'options'参数")
+	})
+
+	t.Run("Запрещённое выражение", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{
+			"query": "INSERT INTO t SELECT * FROM url('http://example.com/data.csv')",
+		}
+
+		result, err := handler.HandleExplainTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "查询被拒绝")
+		mockClient.AssertNotCalled(t, "Explain", mock.Anything, request.Params.Arguments["query"], mock.Anything)
+	})
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleValidateQueryTool(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("ValidateQuery", mock.Anything, "select a from t where a < 1").
+		Return("SELECT a\nFROM t\nWHERE a < 1", nil)
+	mockClient.On("ValidateQuery", mock.Anything, "SELECT a FORM t").
+		Return("", &clickhouse.SyntaxError{
+			Code:    62,
+			Name:    "SYNTAX_ERROR",
+			Message: "Syntax error: failed at position 10 ('FORM'): FORM t. Expected one of: token, Comma, FROM",
+			Line:    1,
+			Column:  10,
+		})
+	mockClient.On("ValidateQuery", mock.Anything, "SELECT 1").
+		Return("", errors.New("ClickHouse连接丢失"))
+
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{})
+
+	t.Run("Корректный запрос", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "select a from t where a < 1"}
+
+		result, err := handler.HandleValidateQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		assert.Contains(t, getText(result), "```sql\nSELECT a\nFROM t\nWHERE a < 1\n```")
+	})
+
+	t.Run("Синтаксическая ошибка", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "SELECT a FORM t"}
+
+		result, err := handler.HandleValidateQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+		var parsed struct {
+			Valid bool                   `json:"valid"`
+			Error clickhouse.SyntaxError `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(getText(result)), &parsed))
+		assert.False(t, parsed.Valid)
+		assert.Equal(t, int32(62), parsed.Error.Code)
+		assert.Equal(t, "SYNTAX_ERROR", parsed.Error.Name)
+		assert.Equal(t, 1, parsed.Error.Line)
+		assert.Equal(t, 10, parsed.Error.Column)
+	})
+
+	t.Run("Ошибка соединения", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "SELECT 1"}
+
+		result, err := handler.HandleValidateQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "检查查询错误")
+	})
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestHandleQueryToolValidate(t *testing.T) {
+	// Создаем мок клиента
+	mockClient := new(MockClickhouseClient)
+	mockClient.On("ValidateQuery", mock.Anything, "SELECT a FORM t").
+		Return("", &clickhouse.SyntaxError{Code: 62, Name: "SYNTAX_ERROR", Message: "Syntax error", Line: 1, Column: 10})
+	mockClient.On("ValidateQuery", mock.Anything, "SELECT 1").Return("SELECT 1", nil)
+	mockClient.On("QueryStream", mock.Anything, "SELECT 1", mock.Anything).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "1", Type: "UInt8", Position: 1}},
+		Rows:    [][]interface{}{{uint8(1)}},
+	}, nil)
+
+	handler := NewToolHandler(singleConnection(mockClient), ToolConfig{ValidateQueries: true})
+
+	t.Run("Запрос с ошибкой не выполняется", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "SELECT a FORM t"}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.True(t, result.IsError)
+		assert.Contains(t, getText(result), "查询未执行")
+		assert.Contains(t, getText(result), `"column": 10`)
+	})
+
+	t.Run("Корректный запрос выполняется", func(t *testing.T) {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"query": "SELECT 1"}
+
+		result, err := handler.HandleQueryTool(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.False(t, result.IsError)
+	})
+
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "QueryStream", mock.Anything, "SELECT a FORM t", mock.Anything)
+}
+
+func TestHandleToolConnection(t *testing.T) {
+	// Создаем моки клиентов двух подключений
+	devClient := new(MockClickhouseClient)
+	devClient.On("GetDatabases", mock.Anything).Return([]string{"dev_db"}, nil)
+	prodClient := new(MockClickhouseClient)
+	prodClient.On("GetDatabases", mock.Anything).Return([]string{"prod_db"}, nil)
+	prodClient.On("QueryStream", mock.Anything, "SELECT 1", mock.Anything).Return(clickhouse.QueryResult{
+		Columns: []clickhouse.ColumnInfo{{Name: "1", Type: "UInt8", Position: 1}},
+		Rows:    [][]interface{}{{uint8(1)}},
+	}, nil)
+
+	handler := NewToolHandler(&mockConnections{clients: map[string]clickhouse.Client{
+		"default": devClient,
+		"prod":    prodClient,
+	}}, ToolConfig{})
+
+	tests := []struct {
+		name      string
+		arguments map[string]interface{}
+		call      func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		want      string
+		wantError bool
+	}{
+		{name: "Подключение по умолчанию", arguments: map[string]interface{}{}, call: handler.HandleGetDatabasesTool, want: "dev_db"},
+		{name: "Выбранное подключение", arguments: map[string]interface{}{"connection": "prod"}, call: handler.HandleGetDatabasesTool, want: "prod_db"},
+		{name: "Запрос в выбранном подключении", arguments: map[string]interface{}{"query": "SELECT 1", "connection": "prod"}, call: handler.HandleQueryTool, want: `"1": 1`},
+		{name: "Неизвестное подключение", arguments: map[string]interface{}{"database": "db", "connection": "stage"}, call: handler.HandleGetTablesTool, want: "stage", wantError: true},
+		{name: "Подключение не строка", arguments: map[string]interface{}{"query": "SELECT 1", "connection": 1.0}, call: handler.HandleValidateQueryTool, want: "'connection'", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := mcp.CallToolRequest{}
+			request.Params.Arguments = tt.arguments
+
+			result, err := tt.call(context.Background(), request)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantError, result.IsError)
+			assert.Contains(t, getText(result), tt.want)
+		})
+	}
+
+	devClient.AssertExpectations(t)
+	prodClient.AssertExpectations(t)
+}
+
+func TestHandleListConnectionsTool(t *testing.T) {
+	statuses := []clickhouse.ConnectionStatus{
+		{
+			Name: "dev", Default: true, Protocol: clickhouse.ProtocolNative, Addr: []string{"dev:9000"},
+			Database: "default", Healthy: true, LatencyMs: 1.5, ServerVersion: "24.8.4", Timezone: "UTC",
+		},
+		{
+			Name: "prod", Protocol: clickhouse.ProtocolHTTP, Addr: []string{"prod:8443"},
+			Database: "logs", Secure: true, ReadOnly: true, Error: "dial tcp: connection refused",
+		},
+	}
+	handler := NewToolHandler(&mockConnections{statuses: statuses}, ToolConfig{})
+
+	result, err := handler.HandleListConnectionsTool(context.Background(), mcp.CallToolRequest{})
+
+	// Недоступное подключение не делает результат ошибкой
+	assert.NoError(t, err)
+	assert.False(t, result.IsError)
+	var parsed struct {
+		Connections []clickhouse.ConnectionStatus `json:"connections"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(getText(result)), &parsed))
+	assert.Equal(t, statuses, parsed.Connections)
+	assert.Contains(t, getText(result), `"server_version": "24.8.4"`)
+}